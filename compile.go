@@ -2,11 +2,11 @@ package slimdown
 
 import (
 	"bytes"
-	"html"
 	"html/template"
 	"net/url"
 	"regexp"
-	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/theTardigrade/golang-slimdown/internal/debug"
 	"github.com/theTardigrade/golang-slimdown/internal/tokenization"
@@ -24,35 +24,22 @@ func CompileString(input string, options *Options) (output template.HTML, err er
 	return Compile([]byte(input), options)
 }
 
+// The tree, with the front matter, table of contents and task counts of
+// the document, is returned by Parse, and can then be passed to Render.
 func Compile(input []byte, options *Options) (output template.HTML, err error) {
-	tokens := tokenization.TokenListCollectionNew(input)
-
 	if options == nil || options == &DefaultOptions {
 		options = DefaultOptions.clone()
 	}
 
-	if err = compileTokenize(options, tokens); err != nil {
+	doc, err := Parse(input, options)
+	if err != nil {
 		return
 	}
 
-	if options.CleanEmptyTokens {
-		compileCleanEmptyTokens(tokens)
-	}
-
-	if options.DebugPrintTokens {
-		debug.PrintTokens(tokens)
-	}
-
-	if err = compileGenerateHTML(options, tokens); err != nil {
+	if output, err = Render(doc, options); err != nil {
 		return
 	}
 
-	if options.CleanEmptyTags {
-		compileCleanEmptyTags(tokens)
-	}
-
-	output = tokens.HTML()
-
 	if options.DebugPrintOutput {
 		debug.PrintOutput(output)
 	}
@@ -60,7 +47,13 @@ func Compile(input []byte, options *Options) (output template.HTML, err error) {
 	return
 }
 
-func compileTokenize(options *Options, tokens *tokenization.TokenListCollection) (err error) {
+// Link definitions are added to the map that is passed in, which may already
+// hold the definitions from earlier parts of a document compiled in parts.
+func compileTokenize(
+	options *Options,
+	tokens *tokenization.TokenListCollection,
+	linkDefinitions map[string]compileTokenizeLinkDefinition,
+) (err error) {
 	var backslashTokens *tokenization.TokenSliceCollection
 	if options.EnableBackslashTransforms {
 		backslashTokens = tokenization.TokenSliceCollectionNew()
@@ -76,6 +69,11 @@ func compileTokenize(options *Options, tokens *tokenization.TokenListCollection)
 		headingTokens = tokenization.TokenSliceCollectionNew()
 	}
 
+	var ruleTokens *tokenization.TokenSliceCollection
+	if options.EnableHeadings || options.EnableHorizontalRules {
+		ruleTokens = tokenization.TokenSliceCollectionNew()
+	}
+
 	var blockquoteTokens *tokenization.TokenSliceCollection
 	if options.EnableBlockquotes {
 		blockquoteTokens = tokenization.TokenSliceCollectionNew()
@@ -86,8 +84,28 @@ func compileTokenize(options *Options, tokens *tokenization.TokenListCollection)
 		linkTokens = tokenization.TokenSliceCollectionNew()
 	}
 
+	var autolinkTokens *tokenization.TokenSliceCollection
+	if options.EnableLinks && options.EnableAutolinks {
+		autolinkTokens = tokenization.TokenSliceCollectionNew()
+	}
+
+	var footnoteTokens *tokenization.TokenSliceCollection
+	if options.EnableFootnotes {
+		footnoteTokens = tokenization.TokenSliceCollectionNew()
+	}
+
+	var delimiterTokens *tokenization.TokenSliceCollection
+	if options.EnableDelTags || options.EnableInsTags || options.EnableSubTags || options.EnableSupTags {
+		delimiterTokens = tokenization.TokenSliceCollectionNew()
+	}
+
+	var tableTokens *tokenization.TokenSliceCollection
+	if options.EnableTables {
+		tableTokens = tokenization.TokenSliceCollectionNew()
+	}
+
 	var listTokens *tokenization.TokenSliceCollection
-	if options.EnableLists {
+	if options.EnableLists || options.EnableOrderedLists {
 		listTokens = tokenization.TokenSliceCollectionNew()
 	}
 
@@ -96,39 +114,89 @@ func compileTokenize(options *Options, tokens *tokenization.TokenListCollection)
 		imageTokens = tokenization.TokenSliceCollectionNew()
 	}
 
+	var lineBreakTokens *tokenization.TokenSliceCollection
+	if options.LineBreakMode != LineBreakModeAlways {
+		lineBreakTokens = tokenization.TokenSliceCollectionNew()
+	}
+
 	var spaceAndTabTokens *tokenization.TokenSliceCollection
 	if options.MaxConsecutiveTabs > 0 || options.MaxConsecutiveSpaces > 0 ||
 		options.SpacesToTab > 0 || options.TabToSpaces > 0 {
 		spaceAndTabTokens = tokenization.TokenSliceCollectionNew()
 	}
 
+	carriageReturnTokens := tokenization.TokenSliceCollectionNew()
+
 	compileTokenizeMain(
 		options,
 		tokens,
+		carriageReturnTokens,
 		backslashTokens,
 		hyphenTokens,
 		headingTokens,
+		ruleTokens,
 		blockquoteTokens,
 		linkTokens,
+		autolinkTokens,
+		footnoteTokens,
+		delimiterTokens,
+		tableTokens,
 		listTokens,
 		imageTokens,
+		lineBreakTokens,
 		spaceAndTabTokens,
 	)
 
+	if delimiterTokens != nil && delimiterTokens.Len() > 0 {
+		if err = compileTokenizeDelimiters(delimiterTokens); err != nil {
+			return
+		}
+	}
+
+	if tableTokens != nil && tableTokens.Len() > 0 {
+		if err = compileTokenizeTables(tableTokens); err != nil {
+			return
+		}
+	}
+
+	if ruleTokens != nil && ruleTokens.Len() > 0 {
+		if err = compileTokenizeRules(ruleTokens, options); err != nil {
+			return
+		}
+	}
+
 	if listTokens != nil && listTokens.Len() > 0 {
-		if err = compileTokenizeLists(listTokens); err != nil {
+		if err = compileTokenizeLists(listTokens, options); err != nil {
+			return
+		}
+	}
+
+	// definitions are taken out before the footnotes, so that a footnote
+	// definition cannot run on into a link definition on the line after it
+	if linkTokens != nil && linkTokens.Len() > 0 {
+		compileTokenizeLinkDefinitions(linkTokens, linkDefinitions, options)
+	}
+
+	if footnoteTokens != nil && footnoteTokens.Len() > 0 {
+		if err = compileTokenizeFootnotes(footnoteTokens); err != nil {
 			return
 		}
 	}
 
 	if imageTokens != nil && imageTokens.Len() > 0 {
-		if err = compileTokenizeImages(imageTokens); err != nil {
+		if err = compileTokenizeImages(imageTokens, options); err != nil {
 			return
 		}
 	}
 
 	if linkTokens != nil && linkTokens.Len() > 0 {
-		if err = compileTokenizeLinks(linkTokens); err != nil {
+		if err = compileTokenizeLinks(linkTokens, linkDefinitions, options); err != nil {
+			return
+		}
+	}
+
+	if autolinkTokens != nil && autolinkTokens.Len() > 0 {
+		if err = compileTokenizeAutolinks(autolinkTokens, options); err != nil {
 			return
 		}
 	}
@@ -146,7 +214,13 @@ func compileTokenize(options *Options, tokens *tokenization.TokenListCollection)
 	}
 
 	if hyphenTokens != nil && hyphenTokens.Len() > 0 {
-		if err = compileTokenizeHyphenTransforms(hyphenTokens); err != nil {
+		if err = compileTokenizeHyphenTransforms(hyphenTokens, options); err != nil {
+			return
+		}
+	}
+
+	if lineBreakTokens != nil && lineBreakTokens.Len() > 0 {
+		if err = compileTokenizeLineBreaks(lineBreakTokens); err != nil {
 			return
 		}
 	}
@@ -163,19 +237,32 @@ func compileTokenize(options *Options, tokens *tokenization.TokenListCollection)
 		}
 	}
 
+	if carriageReturnTokens.Len() > 0 {
+		if err = compileTokenizeCarriageReturns(carriageReturnTokens); err != nil {
+			return
+		}
+	}
+
 	return
 }
 
 func compileTokenizeMain(
 	options *Options,
 	tokens *tokenization.TokenListCollection,
+	carriageReturnTokens,
 	backslashTokens,
 	hyphenTokens,
 	headingTokens,
+	ruleTokens,
 	blockquoteTokens,
 	linkTokens,
+	autolinkTokens,
+	footnoteTokens,
+	delimiterTokens,
+	tableTokens,
 	listTokens,
 	imageTokens,
+	lineBreakTokens,
 	spaceAndTabTokens *tokenization.TokenSliceCollection,
 ) {
 	defer tokens.PushNewEmpty(tokenization.TokenTypeEnd)
@@ -194,10 +281,47 @@ func compileTokenizeMain(
 		tokens.PushNewEmpty(tokenization.TokenTypeDocumentBodyBound)
 	}
 
-	defer tokens.PushNewEmpty(tokenization.TokenTypeParagraphBound)
+	var trailingParagraphBound *tokenization.Token
+
+	defer func() {
+		if trailingParagraphBound != nil && tokens.Peek() == trailingParagraphBound {
+			trailingParagraphBound.Type = tokenization.TokenTypeEmpty
+			return
+		}
+
+		tokens.PushNewEmpty(tokenization.TokenTypeParagraphBound)
+	}()
 	tokens.PushNewEmpty(tokenization.TokenTypeParagraphBound)
 
-	for i, b := range tokens.Input {
+	for i, l := 0, len(tokens.Input); i < l; i++ {
+		b := tokens.Input[i]
+
+		if options.EnableFencedCodeBlocks && (i == 0 || tokens.Input[i-1] == '\n') {
+			if block, ok := compileTokenizeFindFencedCodeBlock(tokens.Input, i); ok {
+				trailingParagraphBound = compileTokenizePushCodeBlock(tokens, block)
+				i = block.endIndex - 1
+				continue
+			}
+		}
+
+		if options.EnableIndentedCodeBlocks && (i == 0 || tokens.Input[i-1] == '\n') {
+			if block, ok := compileTokenizeFindIndentedCodeBlock(tokens.Input, i); ok {
+				trailingParagraphBound = compileTokenizePushCodeBlock(tokens, block)
+				i = block.endIndex - 1
+				continue
+			}
+		}
+
+		if autolinkTokens != nil && compileTokenizeIsAutolinkBoundary(tokens.Input, i) {
+			if endIndex, ok := compileTokenizeFindAutolink(tokens.Input, i); ok {
+				autolinkTokens.Push(tokens.PushNew(tokenization.TokenTypeTextGroup, i, endIndex))
+				// keeps the punctuation after the address out of its text group
+				tokens.PushNewEmpty(tokenization.TokenTypeEmpty)
+				i = endIndex - 1
+				continue
+			}
+		}
+
 		switch b {
 		// TODO: add em and en dashes
 		case 0: // NULL
@@ -253,6 +377,10 @@ func compileTokenizeMain(
 				if listTokens != nil {
 					listTokens.Push(t)
 				}
+
+				if ruleTokens != nil {
+					ruleTokens.Push(t)
+				}
 			}
 		case '_':
 			var match bool
@@ -273,7 +401,11 @@ func compileTokenizeMain(
 			}
 
 			if !match {
-				tokens.PushNewSingle(tokenization.TokenTypeUnderscore, i)
+				t := tokens.PushNewSingle(tokenization.TokenTypeUnderscore, i)
+
+				if ruleTokens != nil {
+					ruleTokens.Push(t)
+				}
 			}
 		case '-':
 			var match bool
@@ -294,11 +426,27 @@ func compileTokenizeMain(
 			}
 
 			if !match {
-				hyphenTokens.Push(
-					tokens.PushNewSingle(tokenization.TokenTypeHyphen, i),
-				)
+				t := tokens.PushNewSingle(tokenization.TokenTypeHyphen, i)
+
+				if hyphenTokens != nil {
+					hyphenTokens.Push(t)
+				}
+
+				if listTokens != nil {
+					listTokens.Push(t)
+				}
+
+				if ruleTokens != nil {
+					ruleTokens.Push(t)
+				}
 			}
 		case '\\':
+			if options.EnableBackslashEscapes && i+1 < l && compileTokenizeIsEscapable(tokens.Input[i+1]) {
+				tokens.PushNew(tokenization.TokenTypeBackslashEscape, i, i+2)
+				i++
+				break
+			}
+
 			t := tokens.PushNewSingle(tokenization.TokenTypeBackslash, i)
 
 			if backslashTokens != nil {
@@ -329,9 +477,11 @@ func compileTokenizeMain(
 			}
 
 			if !match {
-				headingTokens.Push(
-					tokens.PushNewSingle(tokenization.TokenTypeHash, i),
-				)
+				t := tokens.PushNewSingle(tokenization.TokenTypeHash, i)
+
+				if headingTokens != nil {
+					headingTokens.Push(t)
+				}
 			}
 		case '=':
 			var handled bool
@@ -340,13 +490,30 @@ func compileTokenizeMain(
 				if l := t.Len(); l == 1 {
 					if b := t.Bytes(); b[0] == '=' {
 						t.Type = tokenization.TokenTypeEqualsDouble
+						t.InputEndIndex++
 						handled = true
 					}
 				}
 			}
 
 			if !handled {
-				tokens.PushNewSingle(tokenization.TokenTypeTextGroup, i)
+				t := tokens.PushNewSingle(tokenization.TokenTypeTextGroup, i)
+
+				if ruleTokens != nil {
+					ruleTokens.Push(t)
+				}
+			}
+		case '~', '^', '+':
+			if delimiterTokens == nil || !compileTokenizeDelimiter(tokens, delimiterTokens, b, i) {
+				if t := tokens.Peek(); t != nil && t.Type == tokenization.TokenTypeTextGroup {
+					t.InputEndIndex++
+				} else {
+					tokens.PushNewSingle(tokenization.TokenTypeTextGroup, i)
+				}
+			}
+
+			if t := tokens.Peek(); listTokens != nil && b == '+' && t.Type == tokenization.TokenTypeTextGroup && t.InputStartIndex == i {
+				listTokens.Push(t)
 			}
 		case '`':
 			var match bool
@@ -374,11 +541,17 @@ func compileTokenizeMain(
 				imageTokens.Push(t)
 			}
 		case '\r':
-			tokens.PushNewSingle(tokenization.TokenTypeCarriageReturn, i)
-		case '\n':
-			compileTokenizeTransformNewLineBreak(
-				tokens.PushNewSingle(tokenization.TokenTypeLineBreak, i),
+			carriageReturnTokens.Push(
+				tokens.PushNewSingle(tokenization.TokenTypeCarriageReturn, i),
 			)
+		case '\n':
+			t := tokens.PushNewSingle(tokenization.TokenTypeLineBreak, i)
+
+			if lineBreakTokens != nil {
+				lineBreakTokens.Push(t)
+			}
+
+			compileTokenizeTransformNewLineBreak(t)
 		case '\t':
 			if t := tokens.Peek(); t != nil && t.Type == tokenization.TokenTypeTabGroup {
 				t.InputEndIndex++
@@ -393,15 +566,42 @@ func compileTokenizeMain(
 			tokens.PushNewSingle(tokenization.TokenTypeParenthesisOpen, i)
 		case ')':
 			tokens.PushNewSingle(tokenization.TokenTypeParenthesisClose, i)
+		case '|':
+			if tableTokens == nil {
+				if t := tokens.Peek(); t != nil && t.Type == tokenization.TokenTypeTextGroup {
+					t.InputEndIndex++
+				} else {
+					tokens.PushNewSingle(tokenization.TokenTypeTextGroup, i)
+				}
+			} else if t := tokens.Peek(); t != nil && t.Type == tokenization.TokenTypeBackslash {
+				t.Type = tokenization.TokenTypeEmpty
+				tokens.PushNewSingle(tokenization.TokenTypeTextGroup, i)
+			} else {
+				tableTokens.Push(
+					tokens.PushNewSingle(tokenization.TokenTypePipe, i),
+				)
+			}
 		case '[':
 			t := tokens.PushNewSingle(tokenization.TokenTypeSquareBracketOpen, i)
 
 			if linkTokens != nil {
 				linkTokens.Push(t)
 			}
+
+			if footnoteTokens != nil {
+				footnoteTokens.Push(t)
+			}
 		case ']':
 			tokens.PushNewSingle(tokenization.TokenTypeSquareBracketClose, i)
 		case '<':
+			if options.AllowHTML {
+				if tag, ok := compileHTMLFindTag(tokens.Input, i); ok {
+					tokens.PushNew(tokenization.TokenTypeHTMLTag, i, tag.endIndex)
+					i = tag.endIndex - 1
+					break
+				}
+			}
+
 			t := tokens.PushNewSingle(tokenization.TokenTypeAngleBracketOpen, i)
 
 			if linkTokens != nil {
@@ -427,399 +627,2377 @@ func compileTokenizeMain(
 			if t := tokens.Peek(); t != nil && t.Type == tokenization.TokenTypeTextGroup {
 				t.InputEndIndex++
 			} else {
-				tokens.PushNewSingle(tokenization.TokenTypeTextGroup, i)
+				t = tokens.PushNewSingle(tokenization.TokenTypeTextGroup, i)
+
+				if listTokens != nil && b >= '0' && b <= '9' {
+					listTokens.Push(t)
+				}
 			}
 		}
 	}
 }
 
-func compileTokenizeSpacesAndTabs(tokens *tokenization.TokenSliceCollection, options *Options) (err error) {
-	for _, t := range tokens.Tokens {
-		switch t.Type {
-		case tokenization.TokenTypeSpaceGroup:
-			if stt := options.SpacesToTab; stt > 0 {
-				for t.Len() >= stt {
-					if p := t.Prev(); p != nil && p.Type == tokenization.TokenTypeTabGroup {
-						if mct := options.MaxConsecutiveTabs; mct <= 0 || p.Len() < mct {
-							p.InputEndIndex++
-						}
-					} else {
-						t.ListCollection.InsertNewSingleBefore(t, tokenization.TokenTypeTabGroup, t.InputStartIndex)
-					}
+func compileTokenizeDelimiter(
+	tokens *tokenization.TokenListCollection,
+	delimiterTokens *tokenization.TokenSliceCollection,
+	b byte,
+	i int,
+) bool {
+	t := tokens.Peek()
+	isAdjacent := t != nil && t.InputEndIndex == i
+
+	switch b {
+	case '~':
+		if isAdjacent && t.Type == tokenization.TokenTypeTilde {
+			t.Type = tokenization.TokenTypeTildeDouble
+			t.InputEndIndex++
+			return true
+		}
+
+		delimiterTokens.Push(tokens.PushNewSingle(tokenization.TokenTypeTilde, i))
+	case '^':
+		// a caret straight after a square bracket belongs to a footnote label
+		if isAdjacent && t.Type == tokenization.TokenTypeSquareBracketOpen {
+			return false
+		}
+
+		delimiterTokens.Push(tokens.PushNewSingle(tokenization.TokenTypeCaret, i))
+	case '+':
+		// like equals signs, a single plus sign is kept apart from the text before it,
+		// so that a closing pair at the end of a word is found
+		if isAdjacent && t.Type == tokenization.TokenTypeTextGroup && t.Len() == 1 && t.Bytes()[0] == '+' {
+			t.Type = tokenization.TokenTypePlusDouble
+			t.InputEndIndex++
+			delimiterTokens.Push(t)
+			return true
+		}
+
+		tokens.PushNewSingle(tokenization.TokenTypeTextGroup, i)
+	}
 
-					t.InputStartIndex += stt
-				}
+	return true
+}
 
-				if t.Len() == 0 {
-					t.Type = tokenization.TokenTypeEmpty
-				}
-			}
+type compileTokenizeCodeBlock struct {
+	indent            int
+	language          string
+	contentStartIndex int
+	contentEndIndex   int
+	endIndex          int
+}
 
-			if mcs := options.MaxConsecutiveSpaces; mcs > 0 && t.Len() > mcs {
-				t.InputEndIndex = t.InputStartIndex + mcs
-			}
-		case tokenization.TokenTypeTabGroup:
-			if tts := options.TabToSpaces; tts > 0 {
-				t.Type = tokenization.TokenTypeSpaceGroup
-				t.InputEndIndex = t.Len() * tts
+const (
+	compileTokenizeFencedCodeBlockMinFenceLen = 3
+	compileTokenizeFencedCodeBlockMaxIndent   = 3
+)
 
-				if p := t.Prev(); p != nil && p.Type == tokenization.TokenTypeSpaceGroup {
-					p.InputEndIndex += t.Len()
-					t.Type = tokenization.TokenTypeEmpty
-					t = p
-				}
+func compileTokenizeFindFencedCodeBlock(input []byte, i int) (block compileTokenizeCodeBlock, ok bool) {
+	l := len(input)
 
-				if mcs := options.MaxConsecutiveSpaces; mcs > 0 && t.Len() > mcs {
-					t.InputEndIndex = t.InputStartIndex + mcs
-				}
-			}
+	for i < l && input[i] == ' ' {
+		block.indent++
+		i++
+	}
+	if block.indent > compileTokenizeFencedCodeBlockMaxIndent || i >= l {
+		return
+	}
 
-			if t.Type == tokenization.TokenTypeTabGroup {
-				if mct := options.MaxConsecutiveTabs; mct > 0 && t.Len() > mct {
-					t.InputEndIndex = t.InputStartIndex + mct
-				}
-			}
-		}
+	fenceByte := input[i]
+	if fenceByte != '`' && fenceByte != '~' {
+		return
 	}
 
-	return
-}
+	var fenceLen int
+	for i < l && input[i] == fenceByte {
+		fenceLen++
+		i++
+	}
+	if fenceLen < compileTokenizeFencedCodeBlockMinFenceLen {
+		return
+	}
 
-func compileTokenizeTransformNewLineBreak(t *tokenization.Token) {
-	if prev := t.Prev(); prev != nil {
-		if prev.Type == tokenization.TokenTypeCarriageReturn {
-			prev.Type = tokenization.TokenTypeEmpty
-			prev = t.Prev()
+	infoEndIndex := bytes.IndexByte(input[i:], '\n')
+	if infoEndIndex < 0 {
+		infoEndIndex = l
+	} else {
+		infoEndIndex += i
+	}
+
+	info := bytes.TrimSpace(input[i:infoEndIndex])
+	if fenceByte == '`' && bytes.IndexByte(info, '`') >= 0 {
+		return
+	}
+	if fields := bytes.Fields(info); len(fields) > 0 {
+		block.language = string(fields[0])
+	}
+
+	block.contentStartIndex = infoEndIndex + 1
+	if block.contentStartIndex > l {
+		block.contentStartIndex = l
+	}
+	block.contentEndIndex = l
+	block.endIndex = l
+
+	for lineStartIndex := block.contentStartIndex; lineStartIndex < l; {
+		lineEndIndex := bytes.IndexByte(input[lineStartIndex:], '\n')
+		if lineEndIndex < 0 {
+			lineEndIndex = l
+		} else {
+			lineEndIndex += lineStartIndex
 		}
 
-		if prev != nil && prev.Type == tokenization.TokenTypeLineBreak {
-			prev.Type = tokenization.TokenTypeParagraphBound
-			t.Type = tokenization.TokenTypeParagraphBound
+		if compileTokenizeIsClosingFence(input[lineStartIndex:lineEndIndex], fenceByte, fenceLen) {
+			block.contentEndIndex = lineStartIndex
+			block.endIndex = compileTokenizeSkipBlankLines(input, lineEndIndex+1)
+			break
 		}
+
+		lineStartIndex = lineEndIndex + 1
 	}
+
+	ok = true
+
+	return
 }
 
-func compileTokenizeLists(tokens *tokenization.TokenSliceCollection) (err error) {
-	for _, t := range tokens.Tokens {
-		if t.Type != tokenization.TokenTypeAsterisk {
-			continue
-		}
+func compileTokenizeIsClosingFence(line []byte, fenceByte byte, minFenceLen int) bool {
+	line = bytes.TrimRight(line, " \t\r")
 
-		var indent int
-		var firstToken *tokenization.Token
-		prevSpaceTokens, foundPrevSpaceTokens := t.PrevsCollectionUntilEndOfPotentialTypes(
-			tokenization.TokenTypeEmpty,
-			tokenization.TokenTypeSpaceGroup,
-		)
-		if foundPrevSpaceTokens {
-			for _, t2 := range prevSpaceTokens.Tokens {
-				if t2.Type == tokenization.TokenTypeSpaceGroup {
-					indent++
-				}
-			}
+	var indent int
+	for indent < len(line) && line[indent] == ' ' {
+		indent++
+	}
+	if indent > compileTokenizeFencedCodeBlockMaxIndent {
+		return false
+	}
 
-			firstToken = prevSpaceTokens.Get(-1)
-		} else {
-			firstToken = t
-		}
+	line = line[indent:]
+	if len(line) < minFenceLen {
+		return false
+	}
 
-		nextSpaceToken := t.Next()
-		if nextSpaceToken == nil || nextSpaceToken.Type != tokenization.TokenTypeSpaceGroup {
-			continue
+	for _, b := range line {
+		if b != fenceByte {
+			return false
 		}
+	}
 
-		textTokens, foundTextTokens := nextSpaceToken.NextsCollectionUntilMeetType(
-			tokenization.TokenTypeParagraphBound,
-		)
-		if !foundTextTokens {
-			continue
-		}
+	return true
+}
 
-		finalParagraphBoundToken := textTokens.Get(-1).Next()
-		if finalParagraphBoundToken == nil {
-			continue
-		}
+func compileTokenizeSkipBlankLines(input []byte, i int) int {
+	l := len(input)
 
-		finalEmptyToken := finalParagraphBoundToken.RawNext
-		if finalEmptyToken == nil || finalEmptyToken.Type != tokenization.TokenTypeEmpty {
-			continue
+	for i < l {
+		j := i
+		for j < l && (input[j] == ' ' || input[j] == '\t' || input[j] == '\r') {
+			j++
 		}
 
-		prevFirstToken := firstToken.Prev()
-		if prevFirstToken == nil || prevFirstToken.Type != tokenization.TokenTypeParagraphBound {
-			continue
+		if j < l && input[j] != '\n' {
+			break
 		}
 
-		prevPrevFirstToken := prevFirstToken.Prev()
-		if prevPrevFirstToken != nil && prevPrevFirstToken.Type == tokenization.TokenTypeUnorderedListBound {
-			prevPrevFirstToken.Type = tokenization.TokenTypeEmpty
+		i = j + 1
+	}
 
-			if prevPrevFirstToken.Indent == indent {
-				prevFirstToken.Type = tokenization.TokenTypeEmpty
-			} else {
-				prevFirstToken.Type = tokenization.TokenTypeUnorderedListBound
-				prevFirstToken.Indent = indent
-			}
-		} else {
-			prevPrevFirstToken.Type = tokenization.TokenTypeUnorderedListBound
-			prevPrevFirstToken.Indent = indent
-			prevPrevFirstToken.RawPrev.Type = tokenization.TokenTypeParagraphBound
-			prevFirstToken.Type = tokenization.TokenTypeEmpty
-		}
+	if i > l {
+		i = l
+	}
 
-		nextSpaceToken.Type = tokenization.TokenTypeListItemBound
-		t.Type = tokenization.TokenTypeEmpty
+	return i
+}
 
-		// prevEmptyToken.Type = tokenization.TokenTypeListItemBound
-		finalParagraphBoundToken.Type = tokenization.TokenTypeListItemBound
-		finalEmptyToken.Type = tokenization.TokenTypeUnorderedListBound
-		finalEmptyToken.Indent = indent
+const (
+	compileTokenizeIndentedCodeBlockIndent   = 4
+	compileTokenizeIndentedCodeBlockTabWidth = 4
+)
 
-		prevSpaceTokens.SetAllTokenTypesToEmpty()
+// An indented code block may not interrupt a paragraph, and is left alone when
+// the paragraph before it belongs to a list, so that indented list content is
+// not taken for code.
+func compileTokenizeFindIndentedCodeBlock(input []byte, i int) (block compileTokenizeCodeBlock, ok bool) {
+	if !compileTokenizeIsIndentedCodeLine(input, i) {
+		return
+	}
 
-		// TODO: include indent
+	if i > 0 {
+		prevLineStartIndex := bytes.LastIndexByte(input[:i-1], '\n') + 1
+		if !compileTokenizeIsBlankLine(input, prevLineStartIndex) || compileTokenizeFollowsListLines(input, prevLineStartIndex) {
+			return
+		}
 	}
 
-	return
-}
+	l := len(input)
 
-func compileTokenizeImages(tokens *tokenization.TokenSliceCollection) (err error) {
-	for _, t := range tokens.Tokens {
-		if t.Type != tokenization.TokenTypeExclamation {
-			continue
-		}
+	block.indent = compileTokenizeIndentedCodeBlockIndent
+	block.contentStartIndex = i
 
-		squareBracketOpenToken := t.Next()
-		if squareBracketOpenToken == nil || squareBracketOpenToken.Type != tokenization.TokenTypeSquareBracketOpen {
-			continue
+	for lineStartIndex := i; lineStartIndex < l; {
+		lineEndIndex := bytes.IndexByte(input[lineStartIndex:], '\n')
+		if lineEndIndex < 0 {
+			lineEndIndex = l
+		} else {
+			lineEndIndex += lineStartIndex + 1
 		}
 
-		textTokens, foundTextTokens := squareBracketOpenToken.NextsCollectionUntilEndOfPotentialTypes(
-			tokenization.TokenTypeListImageSegmentText...,
-		)
-		if !foundTextTokens {
-			continue
+		if compileTokenizeIsIndentedCodeLine(input, lineStartIndex) {
+			block.contentEndIndex = lineEndIndex
+		} else if !compileTokenizeIsBlankLine(input, lineStartIndex) {
+			break
 		}
 
-		midTokens, foundMidTokens := textTokens.Get(-1).NextNTypesCollection([]tokenization.TokenType{
-			tokenization.TokenTypeSquareBracketClose,
-			tokenization.TokenTypeParenthesisOpen,
+		lineStartIndex = lineEndIndex
+	}
+
+	block.endIndex = compileTokenizeSkipBlankLines(input, block.contentEndIndex)
+	ok = true
+
+	return
+}
+
+func compileTokenizeIsIndentedCodeLine(input []byte, i int) bool {
+	var indent int
+
+	for l := len(input); i < l && indent < compileTokenizeIndentedCodeBlockIndent; i++ {
+		switch input[i] {
+		case ' ':
+			indent++
+		case '\t':
+			indent += compileTokenizeIndentedCodeBlockTabWidth - indent%compileTokenizeIndentedCodeBlockTabWidth
+		default:
+			return false
+		}
+	}
+
+	return indent >= compileTokenizeIndentedCodeBlockIndent && !compileTokenizeIsBlankLine(input, i)
+}
+
+func compileTokenizeIsBlankLine(input []byte, i int) bool {
+	for l := len(input); i < l && input[i] != '\n'; i++ {
+		switch input[i] {
+		case ' ', '\t', '\r':
+			// noop
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// The lines of the paragraph before the blank ones are checked for a list
+// marker or for indentation, either of which would make them part of a list.
+func compileTokenizeFollowsListLines(input []byte, i int) bool {
+	for i > 0 && compileTokenizeIsBlankLine(input, i) {
+		i = bytes.LastIndexByte(input[:i-1], '\n') + 1
+	}
+
+	for !compileTokenizeIsBlankLine(input, i) {
+		switch input[i] {
+		case ' ', '\t':
+			return true
+		}
+
+		if compileTokenizeIsListMarker(input, i) {
+			return true
+		}
+
+		if i == 0 {
+			break
+		}
+
+		i = bytes.LastIndexByte(input[:i-1], '\n') + 1
+	}
+
+	return false
+}
+
+func compileTokenizeIsListMarker(input []byte, i int) bool {
+	l := len(input)
+
+	switch input[i] {
+	case '*', '-', '+':
+		i++
+	default:
+		digitsStartIndex := i
+		for i < l && input[i] >= '0' && input[i] <= '9' {
+			i++
+		}
+
+		if i == digitsStartIndex || i >= l || (input[i] != '.' && input[i] != ')') {
+			return false
+		}
+
+		i++
+	}
+
+	return i < l && (input[i] == ' ' || input[i] == '\t')
+}
+
+func compileTokenizePushCodeBlock(
+	tokens *tokenization.TokenListCollection,
+	block compileTokenizeCodeBlock,
+) (paragraphBound *tokenization.Token) {
+	prevBound := tokens.Peek()
+	if prevBound != nil && prevBound.Type == tokenization.TokenTypeEmpty {
+		prevBound = prevBound.Prev()
+	}
+
+	if prevBound != nil {
+		switch prevBound.Type {
+		case tokenization.TokenTypeParagraphBound:
+			prevBound.Type = tokenization.TokenTypeEmpty
+		case tokenization.TokenTypeLineBreak:
+			prevBound.Type = tokenization.TokenTypeParagraphBound
+		}
+	}
+
+	openToken := tokens.PushNewEmpty(tokenization.TokenTypeCodeBlockBound)
+	openToken.Indent = block.indent
+
+	if block.language != "" {
+		openToken.Attributes = map[string]string{
+			"class": "language-" + block.language,
+		}
+	}
+
+	textToken := tokens.PushNew(tokenization.TokenTypeCodeBlockText, block.contentStartIndex, block.contentEndIndex)
+	textToken.Indent = block.indent
+
+	closeToken := tokens.PushNewEmpty(tokenization.TokenTypeCodeBlockBound)
+	closeToken.Indent = block.indent
+
+	paragraphBound = tokens.PushNewEmpty(tokenization.TokenTypeParagraphBound)
+
+	return
+}
+
+func compileTokenizeSpacesAndTabs(tokens *tokenization.TokenSliceCollection, options *Options) (err error) {
+	for _, t := range tokens.Tokens {
+		switch t.Type {
+		case tokenization.TokenTypeSpaceGroup:
+			if stt := options.SpacesToTab; stt > 0 {
+				for t.Len() >= stt {
+					if p := t.Prev(); p != nil && p.Type == tokenization.TokenTypeTabGroup {
+						if mct := options.MaxConsecutiveTabs; mct <= 0 || p.Len() < mct {
+							p.InputEndIndex++
+						}
+					} else {
+						t.ListCollection.InsertNewSingleBefore(t, tokenization.TokenTypeTabGroup, t.InputStartIndex)
+					}
+
+					t.InputStartIndex += stt
+				}
+
+				if t.Len() == 0 {
+					t.Type = tokenization.TokenTypeEmpty
+				}
+			}
+
+			if mcs := options.MaxConsecutiveSpaces; mcs > 0 && t.Len() > mcs {
+				t.InputEndIndex = t.InputStartIndex + mcs
+			}
+		case tokenization.TokenTypeTabGroup:
+			if tts := options.TabToSpaces; tts > 0 {
+				t.Type = tokenization.TokenTypeSpaceGroup
+				t.InputEndIndex = t.Len() * tts
+
+				if p := t.Prev(); p != nil && p.Type == tokenization.TokenTypeSpaceGroup {
+					p.InputEndIndex += t.Len()
+					t.Type = tokenization.TokenTypeEmpty
+					t = p
+				}
+
+				if mcs := options.MaxConsecutiveSpaces; mcs > 0 && t.Len() > mcs {
+					t.InputEndIndex = t.InputStartIndex + mcs
+				}
+			}
+
+			if t.Type == tokenization.TokenTypeTabGroup {
+				if mct := options.MaxConsecutiveTabs; mct > 0 && t.Len() > mct {
+					t.InputEndIndex = t.InputStartIndex + mct
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func compileTokenizeCarriageReturns(tokens *tokenization.TokenSliceCollection) (err error) {
+	for _, t := range tokens.Tokens {
+		if t.Type != tokenization.TokenTypeCarriageReturn {
+			continue
+		}
+
+		if _, foundNewline := t.NextsCollectionUntilEndOfPotentialTypes(
+			tokenization.TokenTypeParagraphBound,
+			tokenization.TokenTypeLineBreak,
+		); foundNewline {
+			continue
+		}
+
+		prevs, foundPrevs := t.PrevsCollectionUntilStartOfPotentialTypes(
+			tokenization.TokenTypeParagraphBound,
+		)
+		if foundPrevs {
+			for _, t2 := range prevs.Tokens {
+				if t2.Type == tokenization.TokenTypeTextGroup {
+					t2.Type = tokenization.TokenTypeEmpty
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func compileTokenizeTransformNewLineBreak(t *tokenization.Token) {
+	if prev := t.Prev(); prev != nil {
+		if prev.Type == tokenization.TokenTypeCarriageReturn {
+			prev.Type = tokenization.TokenTypeEmpty
+			prev = t.Prev()
+		}
+
+		if prev != nil && prev.Type == tokenization.TokenTypeLineBreak {
+			prev.Type = tokenization.TokenTypeParagraphBound
+			t.Type = tokenization.TokenTypeParagraphBound
+		}
+	}
+}
+
+type compileTokenizeTablesRow struct {
+	startBound   *tokenization.Token
+	endBound     *tokenization.Token
+	cells        [][]*tokenization.Token
+	pipes        []*tokenization.Token
+	outerTokens  *tokenization.TokenSliceCollection
+	leadingPipe  *tokenization.Token
+	trailingPipe *tokenization.Token
+	openBound    *tokenization.Token
+	closeBound   *tokenization.Token
+}
+
+func compileTokenizeTables(tokens *tokenization.TokenSliceCollection) (err error) {
+	var lastStartBound *tokenization.Token
+
+	for _, t := range tokens.Tokens {
+		if t.Type != tokenization.TokenTypePipe {
+			continue
+		}
+
+		headerRow, ok := compileTokenizeTablesFindRow(t)
+		if !ok || headerRow.startBound == lastStartBound {
+			continue
+		}
+		lastStartBound = headerRow.startBound
+
+		delimiterRow, ok := compileTokenizeTablesFindNextRow(headerRow)
+		if !ok {
+			continue
+		}
+
+		alignments, ok := compileTokenizeTablesParseDelimiterRow(delimiterRow)
+		if !ok || len(alignments) != len(headerRow.cells) {
+			continue
+		}
+
+		var bodyRows []*compileTokenizeTablesRow
+
+		for prevRow := delimiterRow; ; {
+			row, ok := compileTokenizeTablesFindNextRow(prevRow)
+			if !ok {
+				break
+			}
+
+			bodyRows = append(bodyRows, row)
+			prevRow = row
+		}
+
+		compileTokenizeTablesTransform(headerRow, delimiterRow, bodyRows, alignments)
+	}
+
+	return
+}
+
+func compileTokenizeTablesFindRow(t *tokenization.Token) (row *compileTokenizeTablesRow, ok bool) {
+	row = &compileTokenizeTablesRow{}
+
+	for p := t.Prev(); p != nil; p = p.Prev() {
+		if p.Type == tokenization.TokenTypeParagraphBound || p.Type == tokenization.TokenTypeLineBreak {
+			row.startBound = p
+			break
+		}
+	}
+	if row.startBound == nil {
+		return
+	}
+
+	row.endBound = t.NextOfTypes(
+		tokenization.TokenTypeParagraphBound,
+		tokenization.TokenTypeLineBreak,
+	)
+	if row.endBound == nil {
+		return
+	}
+
+	lineTokens, foundLineTokens := row.startBound.NextsCollectionUntilStartOfPotentialTypes(
+		tokenization.TokenTypeParagraphBound,
+		tokenization.TokenTypeLineBreak,
+	)
+	if !foundLineTokens {
+		return
+	}
+
+	first, last := 0, lineTokens.Len()-1
+	row.outerTokens = tokenization.TokenSliceCollectionNew()
+
+	for ; first <= last && compileTokenizeTablesIsSpace(lineTokens.Tokens[first]); first++ {
+		row.outerTokens.Push(lineTokens.Tokens[first])
+	}
+	for ; last >= first && compileTokenizeTablesIsSpace(lineTokens.Tokens[last]); last-- {
+		row.outerTokens.Push(lineTokens.Tokens[last])
+	}
+	if first > last {
+		return
+	}
+
+	if t2 := lineTokens.Tokens[first]; t2.Type == tokenization.TokenTypePipe {
+		row.leadingPipe = t2
+		first++
+	}
+	if t2 := lineTokens.Tokens[last]; last >= first && t2.Type == tokenization.TokenTypePipe {
+		row.trailingPipe = t2
+		last--
+	}
+
+	var cell []*tokenization.Token
+
+	for _, t2 := range lineTokens.Tokens[first : last+1] {
+		if t2.Type == tokenization.TokenTypePipe {
+			row.cells = append(row.cells, cell)
+			row.pipes = append(row.pipes, t2)
+			cell = nil
+			continue
+		}
+
+		cell = append(cell, t2)
+	}
+
+	row.cells = append(row.cells, cell)
+
+	if len(row.pipes) == 0 && row.leadingPipe == nil && row.trailingPipe == nil {
+		return
+	}
+
+	ok = true
+
+	return
+}
+
+func compileTokenizeTablesFindNextRow(prevRow *compileTokenizeTablesRow) (row *compileTokenizeTablesRow, ok bool) {
+	if prevRow.endBound.Type != tokenization.TokenTypeLineBreak {
+		return
+	}
+
+	pipe := prevRow.endBound.NextOfTypes(
+		tokenization.TokenTypePipe,
+		tokenization.TokenTypeParagraphBound,
+		tokenization.TokenTypeLineBreak,
+	)
+	if pipe == nil || pipe.Type != tokenization.TokenTypePipe {
+		return
+	}
+
+	if row, ok = compileTokenizeTablesFindRow(pipe); ok {
+		ok = row.startBound == prevRow.endBound
+	}
+
+	return
+}
+
+func compileTokenizeTablesIsSpace(t *tokenization.Token) bool {
+	return t.Type == tokenization.TokenTypeSpaceGroup || t.Type == tokenization.TokenTypeTabGroup
+}
+
+func compileTokenizeTablesParseDelimiterRow(row *compileTokenizeTablesRow) (alignments []string, ok bool) {
+	for _, cell := range row.cells {
+		var alignLeft, alignRight, foundHyphen bool
+
+		for i, t := range cell {
+			switch t.Type {
+			case tokenization.TokenTypeSpaceGroup,
+				tokenization.TokenTypeTabGroup:
+				// noop
+			case tokenization.TokenTypeHyphen,
+				tokenization.TokenTypeHyphenDouble,
+				tokenization.TokenTypeHyphenTriple:
+				if alignRight {
+					return
+				}
+
+				foundHyphen = true
+			case tokenization.TokenTypeTextGroup:
+				if t.String() != ":" {
+					return
+				}
+
+				if !foundHyphen && !alignLeft && i == compileTokenizeTablesFirstNonSpaceIndex(cell) {
+					alignLeft = true
+				} else if foundHyphen && !alignRight {
+					alignRight = true
+				} else {
+					return
+				}
+			default:
+				return
+			}
+		}
+
+		if !foundHyphen {
+			return
+		}
+
+		switch {
+		case alignLeft && alignRight:
+			alignments = append(alignments, "center")
+		case alignLeft:
+			alignments = append(alignments, "left")
+		case alignRight:
+			alignments = append(alignments, "right")
+		default:
+			alignments = append(alignments, "")
+		}
+	}
+
+	ok = true
+
+	return
+}
+
+func compileTokenizeTablesFirstNonSpaceIndex(cell []*tokenization.Token) int {
+	for i, t := range cell {
+		if !compileTokenizeTablesIsSpace(t) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func compileTokenizeTablesTransform(
+	headerRow *compileTokenizeTablesRow,
+	delimiterRow *compileTokenizeTablesRow,
+	bodyRows []*compileTokenizeTablesRow,
+	alignments []string,
+) {
+	tokens := headerRow.startBound.ListCollection
+
+	switch headerRow.startBound.Type {
+	case tokenization.TokenTypeLineBreak:
+		headerRow.startBound.Type = tokenization.TokenTypeParagraphBound
+	default:
+		headerRow.startBound.Type = tokenization.TokenTypeEmpty
+	}
+
+	compileTokenizeTablesTransformRow(headerRow, tokenization.TokenTypeTableHeaderCellBound, alignments)
+
+	tokens.InsertNewEmptyBefore(headerRow.openBound, tokenization.TokenTypeTableBound)
+	tokens.InsertNewEmptyBefore(headerRow.openBound, tokenization.TokenTypeTableHeadBound)
+	lastBound := tokens.InsertNewEmptyAfter(headerRow.closeBound, tokenization.TokenTypeTableHeadBound)
+
+	headerRow.endBound.Type = tokenization.TokenTypeEmpty
+
+	for t := delimiterRow.startBound; t != nil && t != delimiterRow.endBound; t = t.RawNext {
+		t.Type = tokenization.TokenTypeEmpty
+	}
+
+	lastEndBound := delimiterRow.endBound
+
+	if l := len(bodyRows); l > 0 {
+		delimiterRow.endBound.Type = tokenization.TokenTypeEmpty
+
+		for i, row := range bodyRows {
+			compileTokenizeTablesTransformRow(row, tokenization.TokenTypeTableCellBound, alignments)
+
+			if i < l-1 {
+				row.endBound.Type = tokenization.TokenTypeEmpty
+			}
+		}
+
+		tokens.InsertNewEmptyBefore(bodyRows[0].openBound, tokenization.TokenTypeTableBodyBound)
+		lastBound = tokens.InsertNewEmptyAfter(bodyRows[l-1].closeBound, tokenization.TokenTypeTableBodyBound)
+		lastEndBound = bodyRows[l-1].endBound
+	}
+
+	tokens.InsertNewEmptyAfter(lastBound, tokenization.TokenTypeTableBound)
+
+	switch lastEndBound.Type {
+	case tokenization.TokenTypeLineBreak:
+		if next := lastEndBound.Next(); next != nil && next.Type == tokenization.TokenTypeParagraphBound {
+			next.Type = tokenization.TokenTypeEmpty
+			lastEndBound.Type = tokenization.TokenTypeEmpty
+		} else {
+			lastEndBound.Type = tokenization.TokenTypeParagraphBound
+		}
+	default:
+		lastEndBound.Type = tokenization.TokenTypeEmpty
+	}
+}
+
+func compileTokenizeTablesTransformRow(row *compileTokenizeTablesRow, cellType tokenization.TokenType, alignments []string) {
+	tokens := row.startBound.ListCollection
+
+	row.outerTokens.SetAllTokenTypesToEmpty()
+
+	row.openBound = tokens.InsertNewEmptyAfter(row.startBound, tokenization.TokenTypeTableRowBound)
+	row.closeBound = tokens.InsertNewEmptyBefore(row.endBound, tokenization.TokenTypeTableRowBound)
+
+	l := len(alignments)
+
+	for i, cell := range row.cells {
+		var before, after *tokenization.Token
+
+		if i > 0 {
+			before = row.pipes[i-1]
+		} else if row.leadingPipe != nil {
+			before = row.leadingPipe
+		} else {
+			before = row.openBound
+		}
+
+		if i < len(row.pipes) {
+			after = row.pipes[i]
+		} else if row.trailingPipe != nil {
+			after = row.trailingPipe
+		} else {
+			after = row.closeBound
+		}
+
+		before.Type = compileTokenizeTablesEmptyIfPipe(before)
+		after.Type = compileTokenizeTablesEmptyIfPipe(after)
+
+		if i >= l {
+			for _, t := range cell {
+				t.Type = tokenization.TokenTypeEmpty
+			}
+
+			continue
+		}
+
+		for j := 0; j < len(cell) && compileTokenizeTablesIsSpace(cell[j]); j++ {
+			cell[j].Type = tokenization.TokenTypeEmpty
+		}
+		for j := len(cell) - 1; j >= 0 && compileTokenizeTablesIsSpace(cell[j]); j-- {
+			cell[j].Type = tokenization.TokenTypeEmpty
+		}
+
+		compileTokenizeTablesInsertCell(tokens, before, after, cellType, alignments[i])
+	}
+
+	for i := len(row.cells); i < l; i++ {
+		compileTokenizeTablesInsertCell(tokens, row.closeBound.RawPrev, row.closeBound, cellType, alignments[i])
+	}
+}
+
+func compileTokenizeTablesEmptyIfPipe(t *tokenization.Token) tokenization.TokenType {
+	if t.Type == tokenization.TokenTypePipe {
+		return tokenization.TokenTypeEmpty
+	}
+
+	return t.Type
+}
+
+func compileTokenizeTablesInsertCell(
+	tokens *tokenization.TokenListCollection,
+	before, after *tokenization.Token,
+	cellType tokenization.TokenType,
+	alignment string,
+) {
+	openBound := tokens.InsertNewEmptyAfter(before, cellType)
+	tokens.InsertNewEmptyBefore(after, cellType)
+
+	if alignment != "" {
+		openBound.Attributes = map[string]string{"align": alignment}
+	}
+}
+
+const (
+	compileTokenizeRulesMaxIndent           = 3
+	compileTokenizeRulesMinThematicBreakLen = 3
+)
+
+type compileTokenizeRule struct {
+	marker          byte
+	indent          int
+	endIndex        int
+	isUnderline     bool
+	isThematicBreak bool
+}
+
+// Thematic breaks and setext underlines fill a whole line, so both are read
+// from the input rather than from the tokens, which split runs of markers.
+func compileTokenizeFindRule(input []byte, i int) (rule compileTokenizeRule, ok bool) {
+	for j := i - 1; j >= 0 && input[j] != '\n'; j-- {
+		if input[j] != ' ' {
+			return
+		}
+
+		rule.indent++
+	}
+
+	if rule.indent > compileTokenizeRulesMaxIndent {
+		return
+	}
+
+	rule.marker = input[i]
+
+	var markersLen int
+
+	for j, l := i, len(input); j < l && input[j] != '\n' && input[j] != '\r'; j++ {
+		switch input[j] {
+		case rule.marker:
+			markersLen++
+			rule.endIndex = j + 1
+		case ' ', '\t':
+			// noop
+		default:
+			return
+		}
+	}
+
+	switch rule.marker {
+	case '=':
+		rule.isUnderline = rule.endIndex-i == markersLen
+	case '-':
+		rule.isUnderline = rule.endIndex-i == markersLen
+		fallthrough
+	default:
+		rule.isThematicBreak = markersLen >= compileTokenizeRulesMinThematicBreakLen
+	}
+
+	ok = rule.isUnderline || rule.isThematicBreak
+
+	return
+}
+
+func compileTokenizeRules(tokens *tokenization.TokenSliceCollection, options *Options) (err error) {
+	for _, t := range tokens.Tokens {
+		if t.Type == tokenization.TokenTypeEmpty {
+			continue
+		}
+
+		rule, ok := compileTokenizeFindRule(t.ListCollection.Input, t.InputStartIndex)
+		if !ok {
+			continue
+		}
+
+		startBound := t.Prev()
+		for startBound != nil && startBound.Type == tokenization.TokenTypeSpaceGroup {
+			startBound = startBound.Prev()
+		}
+
+		if startBound == nil ||
+			(startBound.Type != tokenization.TokenTypeParagraphBound && startBound.Type != tokenization.TokenTypeLineBreak) {
+			continue
+		}
+
+		endBound := t.NextOfTypes(
+			tokenization.TokenTypeParagraphBound,
+			tokenization.TokenTypeLineBreak,
+		)
+		if endBound == nil {
+			continue
+		}
+
+		if rule.isUnderline && options.EnableHeadings && startBound.Type == tokenization.TokenTypeLineBreak {
+			if openBound := compileTokenizeSetextHeadingOpenBound(startBound, options); openBound != nil {
+				tt := tokenization.TokenTypeHeading2Bound
+				if rule.marker == '=' {
+					tt = tokenization.TokenTypeHeading1Bound
+				}
+
+				openBound.Type = tt
+				startBound.Type = tt
+
+				for t2 := startBound.RawNext; t2 != nil && t2 != endBound; t2 = t2.RawNext {
+					t2.Type = tokenization.TokenTypeEmpty
+				}
+
+				compileTokenizeTransformBlockEndBound(endBound)
+				continue
+			}
+		}
+
+		if rule.isThematicBreak && options.EnableHorizontalRules {
+			for t2 := startBound.RawNext; t2 != nil && t2 != endBound; t2 = t2.RawNext {
+				t2.Type = tokenization.TokenTypeEmpty
+			}
+
+			t.Type = tokenization.TokenTypeHorizontalRule
+			t.InputEndIndex = rule.endIndex
+			t.Indent = rule.indent
+
+			compileTokenizeTransformBlockStartBound(startBound)
+			compileTokenizeTransformBlockEndBound(endBound)
+		}
+	}
+
+	return
+}
+
+// An underline turns the whole paragraph above it into a heading, unless one of
+// its lines starts a block of its own.
+func compileTokenizeSetextHeadingOpenBound(underlineBound *tokenization.Token, options *Options) *tokenization.Token {
+	var lineStartToken *tokenization.Token
+
+	for t := underlineBound.Prev(); t != nil; t = t.Prev() {
+		switch t.Type {
+		case tokenization.TokenTypeParagraphBound,
+			tokenization.TokenTypeLineBreak:
+			if lineStartToken == nil || compileTokenizeStartsBlock(lineStartToken, options) {
+				return nil
+			}
+
+			if t.Type == tokenization.TokenTypeParagraphBound {
+				return t
+			}
+
+			lineStartToken = nil
+		case tokenization.TokenTypeSpaceGroup,
+			tokenization.TokenTypeTabGroup,
+			tokenization.TokenTypeCarriageReturn:
+			// noop
+		case tokenization.TokenTypeStart,
+			tokenization.TokenTypeDocumentBodyBound,
+			tokenization.TokenTypeCodeBlockBound,
+			tokenization.TokenTypeHorizontalRule,
+			tokenization.TokenTypeTableBound:
+			return nil
+		default:
+			lineStartToken = t
+		}
+	}
+
+	return nil
+}
+
+func compileTokenizeStartsBlock(t *tokenization.Token, options *Options) bool {
+	switch t.Type {
+	case tokenization.TokenTypeHash,
+		tokenization.TokenTypeHashDouble,
+		tokenization.TokenTypeHashTriple,
+		tokenization.TokenTypeHashQuadruple,
+		tokenization.TokenTypeHashQuintuple,
+		tokenization.TokenTypeHashSextuple,
+		tokenization.TokenTypeAngleBracketClose:
+		next := t.Next()
+		return next != nil && next.Type == tokenization.TokenTypeSpaceGroup
+	}
+
+	_, isItem := compileTokenizeListsFindItem(t, options)
+
+	return isItem
+}
+
+// A block that takes up whole lines closes the paragraph that it interrupts,
+// and the text straight after it starts a new one.
+func compileTokenizeTransformBlockStartBound(startBound *tokenization.Token) {
+	if startBound.Type == tokenization.TokenTypeLineBreak {
+		startBound.Type = tokenization.TokenTypeParagraphBound
+	} else {
+		startBound.Type = tokenization.TokenTypeEmpty
+	}
+}
+
+func compileTokenizeTransformBlockEndBound(endBound *tokenization.Token) {
+	switch endBound.Type {
+	case tokenization.TokenTypeLineBreak:
+		if next := endBound.Next(); next != nil && next.Type == tokenization.TokenTypeParagraphBound {
+			next.Type = tokenization.TokenTypeEmpty
+			endBound.Type = tokenization.TokenTypeEmpty
+		} else {
+			endBound.Type = tokenization.TokenTypeParagraphBound
+		}
+	default:
+		endBound.Type = tokenization.TokenTypeEmpty
+	}
+}
+
+const (
+	compileTokenizeListsTabWidth         = 4
+	compileTokenizeListsMaxOrderedDigits = 9
+	compileTokenizeListsMaxContentSpaces = 4
+	compileTokenizeListsTaskListClass    = "task-list"
+)
+
+type compileTokenizeListsItem struct {
+	listType      tokenization.TokenType
+	delimiter     byte
+	number        int
+	indent        int
+	contentIndent int
+	startBound    *tokenization.Token
+	firstToken    *tokenization.Token
+	markerTokens  *tokenization.TokenSliceCollection
+	itemBound     *tokenization.Token
+	closingBound  *tokenization.Token
+	indentTokens  *tokenization.TokenSliceCollection
+	blocks        []*compileTokenizeListsBlock
+	lastLine      *compileTokenizeListsLine
+	isInterrupter bool
+	isLoose       bool
+}
+
+type compileTokenizeListsList struct {
+	items   []*compileTokenizeListsItem
+	isLoose bool
+}
+
+// A block is either a paragraph, a fenced code block or a nested list.
+type compileTokenizeListsBlock struct {
+	lines []*compileTokenizeListsLine
+	list  *compileTokenizeListsList
+}
+
+type compileTokenizeListsLine struct {
+	startBound     *tokenization.Token
+	endBound       *tokenization.Token
+	contentToken   *tokenization.Token
+	indentTokens   *tokenization.TokenSliceCollection
+	indent         int
+	item           *compileTokenizeListsItem
+	isBlank        bool
+	isBlock        bool
+	isContinuation bool
+	isAfterBlank   bool
+}
+
+// Lines are only read as far as the list needs them, so the pass stays linear
+// however many lists a document has.
+type compileTokenizeListsParser struct {
+	options    *Options
+	startBound *tokenization.Token
+	lines      []*compileTokenizeListsLine
+}
+
+func compileTokenizeLists(tokens *tokenization.TokenSliceCollection, options *Options) (err error) {
+	for _, t := range tokens.Tokens {
+		item, ok := compileTokenizeListsFindItem(t, options)
+		if !ok {
+			continue
+		}
+
+		if item.isInterrupter && item.listType == tokenization.TokenTypeOrderedListBound && item.number != 1 {
+			continue
+		}
+
+		p := &compileTokenizeListsParser{
+			options:    options,
+			startBound: item.startBound,
+		}
+
+		if p.line(0) == nil {
+			continue
+		}
+
+		list, next := p.parseList(0, 0)
+
+		compileTokenizeListsTransform(list, options)
+		compileTokenizeListsTransformLines(p.lines[:next])
+	}
+
+	return
+}
+
+func (p *compileTokenizeListsParser) line(k int) *compileTokenizeListsLine {
+	for len(p.lines) <= k {
+		startBound := p.startBound
+		if l := len(p.lines); l > 0 {
+			startBound = p.lines[l-1].endBound
+		}
+
+		line, ok := compileTokenizeListsReadLine(startBound, p.options)
+		if !ok {
+			return nil
+		}
+
+		p.lines = append(p.lines, line)
+	}
+
+	return p.lines[k]
+}
+
+// As in CommonMark, an item whose marker differs from the one before it (a "-"
+// after a "*", or a ")" after a ".") starts a new list rather than joining it.
+func (p *compileTokenizeListsParser) parseList(k, minIndent int) (list *compileTokenizeListsList, next int) {
+	list = &compileTokenizeListsList{}
+
+	for {
+		line := p.line(k)
+		item := line.item
+
+		item.lastLine = line
+
+		if item.itemBound.Next() != line.endBound {
+			item.blocks = []*compileTokenizeListsBlock{{lines: []*compileTokenizeListsLine{line}}}
+		}
+
+		list.items = append(list.items, item)
+		next = p.parseItem(item, k+1)
+
+		if item.isLoose {
+			list.isLoose = true
+		}
+
+		k = next
+		for l := p.line(k); l != nil && l.isBlank; l = p.line(k) {
+			k++
+		}
+
+		l := p.line(k)
+		if l == nil || l.item == nil || l.indent < minIndent || l.indent >= item.contentIndent ||
+			l.item.listType != item.listType || l.item.delimiter != item.delimiter {
+			return
+		}
+	}
+}
+
+// Lines indented to the content of an item belong to it, and so does a line
+// that is not indented at all, as long as it carries on a paragraph.
+func (p *compileTokenizeListsParser) parseItem(item *compileTokenizeListsItem, k int) (next int) {
+	next = k
+	lastBlock := &compileTokenizeListsBlock{}
+
+	if len(item.blocks) > 0 {
+		lastBlock = item.blocks[0]
+	}
+
+	var hasBlank bool
+
+	for l := p.line(k); l != nil; l = p.line(k) {
+		if l.isBlank {
+			hasBlank = true
+			k++
+			continue
+		}
+
+		if l.isAfterBlank {
+			hasBlank = true
+		}
+
+		isParagraph := len(lastBlock.lines) > 0 && !lastBlock.lines[0].isBlock
+
+		if l.indent >= item.contentIndent {
+			if hasBlank {
+				item.isLoose = true
+			}
+
+			switch {
+			case l.item != nil:
+				lastBlock = &compileTokenizeListsBlock{}
+				lastBlock.list, k = p.parseList(k, item.contentIndent)
+				item.blocks = append(item.blocks, lastBlock)
+			case isParagraph && !hasBlank && !l.isBlock:
+				l.isContinuation = true
+				lastBlock.lines = append(lastBlock.lines, l)
+				k++
+			default:
+				lastBlock = &compileTokenizeListsBlock{lines: []*compileTokenizeListsLine{l}}
+				item.blocks = append(item.blocks, lastBlock)
+				k++
+			}
+		} else if !hasBlank && isParagraph && l.item == nil && compileTokenizeListsIsLazyLine(l) {
+			l.isContinuation = true
+			lastBlock.lines = append(lastBlock.lines, l)
+			k++
+		} else {
+			break
+		}
+
+		hasBlank = false
+		next = k
+		item.lastLine = p.lines[k-1]
+	}
+
+	return
+}
+
+func compileTokenizeListsReadLine(startBound *tokenization.Token, options *Options) (line *compileTokenizeListsLine, ok bool) {
+	line = &compileTokenizeListsLine{
+		startBound:   startBound,
+		indentTokens: tokenization.TokenSliceCollectionNew(),
+	}
+
+	t := startBound.Next()
+
+	for ; t != nil; t = t.Next() {
+		if t.Type == tokenization.TokenTypeSpaceGroup {
+			line.indent += t.Len()
+		} else if t.Type == tokenization.TokenTypeTabGroup {
+			line.indent += t.Len() * compileTokenizeListsTabWidth
+		} else if t.Type != tokenization.TokenTypeCarriageReturn {
+			break
+		}
+
+		line.indentTokens.Push(t)
+	}
+
+	if t == nil {
+		return
+	}
+
+	switch t.Type {
+	case tokenization.TokenTypeEnd,
+		tokenization.TokenTypeDocumentBodyBound,
+		tokenization.TokenTypeDocumentHTMLBound:
+		return
+	case tokenization.TokenTypeParagraphBound,
+		tokenization.TokenTypeLineBreak:
+		line.isBlank = true
+		line.endBound = t
+	case tokenization.TokenTypeCodeBlockBound,
+		tokenization.TokenTypeHorizontalRule:
+		line.contentToken = t
+		line.indent = t.Indent
+		line.isBlock = true
+
+		// the fenced code block and rule passes drop the bound of a blank line before them
+		if input := t.ListCollection.Input; startBound.InputEndIndex > startBound.InputStartIndex {
+			rest := bytes.TrimLeft(input[startBound.InputEndIndex:], " \t\r")
+			line.isAfterBlank = len(rest) > 0 && rest[0] == '\n'
+		}
+
+		if t.Type == tokenization.TokenTypeHorizontalRule {
+			line.endBound = t.Next()
+		} else if closeBound := t.NextOfType(tokenization.TokenTypeCodeBlockBound); closeBound != nil {
+			line.endBound = closeBound.Next()
+		}
+	default:
+		line.contentToken = t
+		line.endBound = t.NextOfTypes(
+			tokenization.TokenTypeParagraphBound,
+			tokenization.TokenTypeLineBreak,
+		)
+
+		if item, isItem := compileTokenizeListsFindItem(t, options); isItem {
+			line.item = item
+		}
+	}
+
+	ok = line.endBound != nil
+
+	return
+}
+
+// A lazy line carries on the paragraph of an item without being indented,
+// unless it starts a block of its own.
+func compileTokenizeListsIsLazyLine(line *compileTokenizeListsLine) bool {
+	if line.isBlock {
+		return false
+	}
+
+	t := line.contentToken
+
+	switch t.Type {
+	case tokenization.TokenTypeHash,
+		tokenization.TokenTypeHashDouble,
+		tokenization.TokenTypeHashTriple,
+		tokenization.TokenTypeHashQuadruple,
+		tokenization.TokenTypeHashQuintuple,
+		tokenization.TokenTypeHashSextuple,
+		tokenization.TokenTypeAngleBracketClose,
+		tokenization.TokenTypeTableBound:
+		return false
+	}
+
+	return true
+}
+
+func compileTokenizeListsFindItem(t *tokenization.Token, options *Options) (item *compileTokenizeListsItem, ok bool) {
+	item = &compileTokenizeListsItem{
+		markerTokens: tokenization.TokenSliceCollectionNew(),
+	}
+
+	var nextSpace *tokenization.Token
+
+	b := t.Bytes()
+	l := len(b)
+
+	switch t.Type {
+	case tokenization.TokenTypeAsterisk,
+		tokenization.TokenTypeHyphen:
+		if !options.EnableLists {
+			return
+		}
+
+		item.listType = tokenization.TokenTypeUnorderedListBound
+		item.delimiter = b[0]
+		item.markerTokens.Push(t)
+		nextSpace = t.Next()
+	case tokenization.TokenTypeTextGroup:
+		if l == 1 && b[0] == '+' {
+			if !options.EnableLists {
+				return
+			}
+
+			item.listType = tokenization.TokenTypeUnorderedListBound
+			item.delimiter = '+'
+			item.markerTokens.Push(t)
+			nextSpace = t.Next()
+			break
+		}
+
+		if !options.EnableOrderedLists {
+			return
+		}
+
+		digitsLen := l
+
+		if next := t.Next(); next != nil && next.Type == tokenization.TokenTypeParenthesisClose {
+			item.delimiter = ')'
+			item.markerTokens.Push(t, next)
+			nextSpace = next.Next()
+		} else if l > 0 && b[l-1] == '.' {
+			item.delimiter = '.'
+			item.markerTokens.Push(t)
+			nextSpace = t.Next()
+			digitsLen--
+		} else {
+			return
+		}
+
+		if digitsLen <= 0 || digitsLen > compileTokenizeListsMaxOrderedDigits {
+			return
+		}
+
+		for _, d := range b[:digitsLen] {
+			if d < '0' || d > '9' {
+				return
+			}
+
+			item.number = item.number*10 + int(d-'0')
+		}
+
+		item.listType = tokenization.TokenTypeOrderedListBound
+	default:
+		return
+	}
+
+	if nextSpace == nil || nextSpace.Type != tokenization.TokenTypeSpaceGroup {
+		return
+	}
+
+	item.firstToken = t
+	item.indentTokens = tokenization.TokenSliceCollectionNew()
+
+	prev := t.Prev()
+	for prev != nil && (prev.Type == tokenization.TokenTypeSpaceGroup || prev.Type == tokenization.TokenTypeTabGroup) {
+		if prev.Type == tokenization.TokenTypeTabGroup {
+			item.indent += prev.Len() * compileTokenizeListsTabWidth
+		} else {
+			item.indent += prev.Len()
+		}
+
+		item.indentTokens.Push(prev)
+		item.firstToken = prev
+		prev = prev.Prev()
+	}
+
+	if prev == nil {
+		return
+	}
+
+	switch prev.Type {
+	case tokenization.TokenTypeParagraphBound:
+	case tokenization.TokenTypeLineBreak:
+		item.isInterrupter = true
+	default:
+		return
+	}
+
+	item.startBound = prev
+	item.itemBound = nextSpace
+
+	item.contentIndent = item.indent + nextSpace.Len()
+	if nextSpace.Len() > compileTokenizeListsMaxContentSpaces {
+		item.contentIndent = item.indent + 1
+	}
+
+	for _, m := range item.markerTokens.Tokens {
+		item.contentIndent += m.Len()
+	}
+
+	ok = true
+
+	return
+}
+
+func compileTokenizeListsTransform(list *compileTokenizeListsList, options *Options) {
+	firstItem, lastItem := list.items[0], list.items[len(list.items)-1]
+	tokens := firstItem.firstToken.ListCollection
+
+	listOpenToken := tokens.InsertNewEmptyBefore(firstItem.firstToken, firstItem.listType)
+	listOpenToken.Indent = firstItem.indent
+
+	if firstItem.listType == tokenization.TokenTypeOrderedListBound && firstItem.number != 1 {
+		listOpenToken.Attributes = map[string]string{
+			"start": strconv.Itoa(firstItem.number),
+		}
+	}
+
+	for _, item := range list.items {
+		item.indentTokens.SetAllTokenTypesToEmpty()
+		item.markerTokens.SetAllTokenTypesToEmpty()
+
+		item.itemBound.Type = tokenization.TokenTypeListItemBound
+		item.itemBound.Indent = firstItem.indent
+
+		if options.EnableTaskLists && compileTokenizeListsTaskCheckbox(item) {
+			if listOpenToken.Attributes == nil {
+				listOpenToken.Attributes = make(map[string]string)
+			}
+
+			listOpenToken.Attributes["class"] = compileTokenizeListsTaskListClass
+		}
+
+		for _, block := range item.blocks {
+			if block.list != nil {
+				compileTokenizeListsTransform(block.list, options)
+				continue
+			}
+
+			if !list.isLoose || block.lines[0].isBlock {
+				continue
+			}
+
+			if firstLine := block.lines[0]; firstLine.item == item {
+				tokens.InsertNewEmptyAfter(item.itemBound, tokenization.TokenTypeParagraphBound)
+			} else {
+				tokens.InsertNewEmptyBefore(firstLine.contentToken, tokenization.TokenTypeParagraphBound)
+			}
+
+			tokens.InsertNewEmptyBefore(block.lines[len(block.lines)-1].endBound, tokenization.TokenTypeParagraphBound)
+		}
+
+		item.closingBound = tokens.InsertNewEmptyBefore(item.lastLine.endBound, tokenization.TokenTypeListItemBound)
+		item.closingBound.Indent = firstItem.indent
+	}
+
+	listCloseToken := tokens.InsertNewEmptyAfter(lastItem.closingBound, firstItem.listType)
+	listCloseToken.Indent = firstItem.indent
+}
+
+// Line breaks are only kept within a paragraph.
+func compileTokenizeListsTransformLines(lines []*compileTokenizeListsLine) {
+	for i, line := range lines {
+		line.indentTokens.SetAllTokenTypesToEmpty()
+
+		if i > 0 && !line.isContinuation {
+			line.startBound.Type = tokenization.TokenTypeEmpty
+		}
+	}
+
+	compileTokenizeTransformBlockStartBound(lines[0].startBound)
+
+	if lastLine := lines[len(lines)-1]; !lastLine.isBlock {
+		compileTokenizeTransformBlockEndBound(lastLine.endBound)
+	}
+}
+
+// The square brackets at the start of a task list item are turned into a single
+// token, before the link and footnote passes can treat them as a reference.
+func compileTokenizeListsTaskCheckbox(item *compileTokenizeListsItem) bool {
+	openToken := item.itemBound.Next()
+	if openToken == nil || openToken.Type != tokenization.TokenTypeSquareBracketOpen {
+		return false
+	}
+
+	stateToken := openToken.Next()
+	if stateToken == nil || stateToken.InputStartIndex != openToken.InputEndIndex || stateToken.Len() != 1 {
+		return false
+	}
+
+	var isChecked bool
+
+	switch stateToken.Type {
+	case tokenization.TokenTypeSpaceGroup:
+	case tokenization.TokenTypeTextGroup:
+		if b := stateToken.Bytes()[0]; b != 'x' && b != 'X' {
+			return false
+		}
+
+		isChecked = true
+	default:
+		return false
+	}
+
+	closeToken := stateToken.Next()
+	if closeToken == nil || closeToken.Type != tokenization.TokenTypeSquareBracketClose ||
+		closeToken.InputStartIndex != stateToken.InputEndIndex {
+		return false
+	}
+
+	if next := closeToken.Next(); next != nil {
+		switch next.Type {
+		case tokenization.TokenTypeSpaceGroup,
+			tokenization.TokenTypeParagraphBound,
+			tokenization.TokenTypeLineBreak:
+		default:
+			return false
+		}
+	}
+
+	stateToken.Type = tokenization.TokenTypeEmpty
+	closeToken.Type = tokenization.TokenTypeEmpty
+
+	openToken.Type = tokenization.TokenTypeTaskCheckbox
+	openToken.InputEndIndex = closeToken.InputEndIndex
+	openToken.Attributes = map[string]string{
+		"disabled": "",
+		"type":     "checkbox",
+	}
+
+	if isChecked {
+		openToken.Attributes["checked"] = ""
+	}
+
+	return true
+}
+
+func compileTokenizeImages(tokens *tokenization.TokenSliceCollection, options *Options) (err error) {
+	for _, t := range tokens.Tokens {
+		if t.Type != tokenization.TokenTypeExclamation {
+			continue
+		}
+
+		squareBracketOpenToken := t.Next()
+		if squareBracketOpenToken == nil || squareBracketOpenToken.Type != tokenization.TokenTypeSquareBracketOpen {
+			continue
+		}
+
+		textTokens, foundTextTokens := squareBracketOpenToken.NextsCollectionUntilEndOfPotentialTypes(
+			tokenization.TokenTypeListImageSegmentText...,
+		)
+		if !foundTextTokens {
+			continue
+		}
+
+		midTokens, foundMidTokens := textTokens.Get(-1).NextNTypesCollection([]tokenization.TokenType{
+			tokenization.TokenTypeSquareBracketClose,
+			tokenization.TokenTypeParenthesisOpen,
 		})
 		if !foundMidTokens {
 			continue
 		}
 
-		linkTokens, foundLinkTokens := midTokens.Get(-1).NextsCollectionUntilEndOfPotentialTypes(
-			tokenization.TokenTypeListImageSegmentLink...,
+		linkTokens, foundLinkTokens := midTokens.Get(-1).NextsCollectionUntilEndOfPotentialTypes(
+			tokenization.TokenTypeListImageSegmentLink...,
+		)
+		if !foundLinkTokens {
+			continue
+		}
+
+		lastLinkToken := linkTokens.Get(-1)
+		var finalToken *tokenization.Token
+		var titleTokens *tokenization.TokenSliceCollection
+
+		spaceTokens, foundSpaceTokens := lastLinkToken.NextsCollectionUntilEndOfPotentialTypes(
+			tokenization.TokenTypeSpaceGroup,
+		)
+		if foundSpaceTokens {
+			var foundTitleTokens bool
+			titleTokens, foundTitleTokens = spaceTokens.Get(-1).NextsCollectionUntilEndOfPotentialTypes(
+				tokenization.TokenTypeListImageSegmentTitle...,
+			)
+			if !foundTitleTokens {
+				continue
+			}
+
+			finalToken = titleTokens.Get(-1).Next()
+		} else {
+			finalToken = lastLinkToken.Next()
+		}
+
+		if finalToken == nil || finalToken.Type != tokenization.TokenTypeParenthesisClose {
+			continue
+		}
+
+		var linkBuff, textBuff bytes.Buffer
+
+		for _, t2 := range linkTokens.Tokens {
+			linkBuff.Write(compileTokenizeUnescapedBytes(t2))
+		}
+		for _, t2 := range textTokens.Tokens {
+			textBuff.Write(compileTokenizeUnescapedBytes(t2))
+		}
+
+		linkString := linkBuff.String()
+		textString := textBuff.String()
+
+		linkURL, err2 := url.Parse(linkString)
+		if err2 != nil {
+			continue
+		}
+
+		if !compileURLIsAllowed(linkString, options) {
+			if !options.RenderUnsafeURLsAsText {
+				// the alt text is kept, as the text of an unsafe link is
+				t.Type = tokenization.TokenTypeEmpty
+				squareBracketOpenToken.Type = tokenization.TokenTypeEmpty
+				midTokens.SetAllTokenTypesToEmpty()
+				linkTokens.SetAllTokenTypesToEmpty()
+				finalToken.Type = tokenization.TokenTypeEmpty
+
+				if foundSpaceTokens {
+					spaceTokens.SetAllTokenTypesToEmpty()
+					titleTokens.SetAllTokenTypesToEmpty()
+				}
+			}
+
+			continue
+		}
+
+		linkString = linkURL.String()
+
+		t.Type = tokenization.TokenTypeImageBound
+		t.Attributes = map[string]string{
+			"alt": textString,
+			"src": linkString,
+		}
+
+		if foundSpaceTokens {
+			var titleBuff bytes.Buffer
+
+			for _, t2 := range titleTokens.Tokens {
+				titleBuff.Write(compileTokenizeUnescapedBytes(t2))
+			}
+
+			t.Attributes["title"] = compileTokenizeTrimTitleQuotes(titleBuff.String())
+
+			spaceTokens.SetAllTokenTypesToEmpty()
+			titleTokens.SetAllTokenTypesToEmpty()
+		}
+
+		textTokens.SetAllTokenTypesToEmpty()
+		midTokens.SetAllTokenTypesToEmpty()
+		linkTokens.SetAllTokenTypesToEmpty()
+
+		squareBracketOpenToken.Type = tokenization.TokenTypeImageBound
+
+		finalToken.Type = tokenization.TokenTypeEmpty
+	}
+
+	return
+}
+
+var (
+	compileTokenizeLinksEmailRegexp = regexp.MustCompile(
+		"^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$",
+	)
+)
+
+func compileTokenizeLinks(
+	tokens *tokenization.TokenSliceCollection,
+	definitions map[string]compileTokenizeLinkDefinition,
+	options *Options,
+) (err error) {
+	for _, t := range tokens.Tokens {
+		var textTokens, midTokens, linkTokens, spaceTokens, titleTokens *tokenization.TokenSliceCollection
+		var foundTextTokens, foundMidTokens, foundLinkTokens, foundSpaceTokens bool
+		var finalToken *tokenization.Token
+		var expectedFinalTokenType tokenization.TokenType
+
+		switch t.Type {
+		case tokenization.TokenTypeSquareBracketOpen:
+			textTokens, foundTextTokens = t.NextsCollectionUntilEndOfPotentialTypes(
+				tokenization.TokenTypeListLinkSegmentText...,
+			)
+			if !foundTextTokens {
+				continue
+			}
+
+			midTokens, foundMidTokens = textTokens.Get(-1).NextNTypesCollection([]tokenization.TokenType{
+				tokenization.TokenTypeSquareBracketClose,
+				tokenization.TokenTypeParenthesisOpen,
+			})
+			if !foundMidTokens {
+				continue
+			}
+
+			linkTokens, foundLinkTokens = midTokens.Get(-1).NextsCollectionUntilEndOfPotentialTypes(
+				tokenization.TokenTypeListLinkSegmentLink...,
+			)
+			if !foundLinkTokens {
+				continue
+			}
+
+			expectedFinalTokenType = tokenization.TokenTypeParenthesisClose
+		case tokenization.TokenTypeAngleBracketOpen:
+			linkTokens, foundLinkTokens = t.NextsCollectionUntilEndOfPotentialTypes(
+				tokenization.TokenTypeListLinkSegmentLink...,
+			)
+			if !foundLinkTokens {
+				continue
+			}
+
+			expectedFinalTokenType = tokenization.TokenTypeAngleBracketClose
+		default:
+			continue
+		}
+
+		{
+			lastLinkToken := linkTokens.Get(-1)
+			spaceTokens, foundSpaceTokens = lastLinkToken.NextsCollectionUntilEndOfPotentialTypes(
+				tokenization.TokenTypeSpaceGroup,
+			)
+			if foundSpaceTokens {
+				var foundTitleTokens bool
+				titleTokens, foundTitleTokens = spaceTokens.Get(-1).NextsCollectionUntilEndOfPotentialTypes(
+					tokenization.TokenTypeListLinkSegmentTitle...,
+				)
+				if !foundTitleTokens {
+					continue
+				}
+
+				finalToken = titleTokens.Get(-1).Next()
+			} else {
+				finalToken = lastLinkToken.Next()
+			}
+
+			if finalToken == nil || finalToken.Type != expectedFinalTokenType {
+				continue
+			}
+		}
+
+		var linkBuff bytes.Buffer
+
+		for _, t2 := range linkTokens.Tokens {
+			linkBuff.Write(compileTokenizeUnescapedBytes(t2))
+		}
+
+		linkString, ok := compileTokenizeLinksURL(linkBuff.String())
+		if !ok {
+			continue
+		}
+
+		if !compileURLIsAllowed(linkString, options) {
+			if !options.RenderUnsafeURLsAsText {
+				t.Type = tokenization.TokenTypeEmpty
+				finalToken.Type = tokenization.TokenTypeEmpty
+
+				// the address of an autolink is its text, so it is kept
+				if foundTextTokens {
+					linkTokens.SetAllTokenTypesToEmpty()
+				}
+
+				if foundSpaceTokens {
+					spaceTokens.SetAllTokenTypesToEmpty()
+					titleTokens.SetAllTokenTypesToEmpty()
+				}
+
+				if foundMidTokens {
+					midTokens.SetAllTokenTypesToEmpty()
+				}
+			}
+
+			continue
+		}
+
+		t.Type = tokenization.TokenTypeLinkBound
+		t.Attributes = map[string]string{"href": linkString}
+
+		if foundSpaceTokens {
+			var titleBuff bytes.Buffer
+
+			for _, t2 := range titleTokens.Tokens {
+				titleBuff.Write(compileTokenizeUnescapedBytes(t2))
+			}
+
+			t.Attributes["title"] = compileTokenizeTrimTitleQuotes(titleBuff.String())
+
+			spaceTokens.SetAllTokenTypesToEmpty()
+			titleTokens.SetAllTokenTypesToEmpty()
+		}
+
+		if foundMidTokens {
+			midTokens.SetAllTokenTypesToEmpty()
+		}
+
+		if foundTextTokens {
+			linkTokens.SetAllTokenTypesToEmpty()
+		}
+
+		finalToken.Type = tokenization.TokenTypeLinkBound
+	}
+
+	if len(definitions) > 0 {
+		for _, t := range tokens.Tokens {
+			if t.Type == tokenization.TokenTypeSquareBracketOpen {
+				compileTokenizeLinksReference(t, definitions, options)
+			}
+		}
+	}
+
+	return
+}
+
+type compileTokenizeFootnoteLabel struct {
+	label      string
+	closeToken *tokenization.Token
+}
+
+func compileTokenizeFootnotes(tokens *tokenization.TokenSliceCollection) (err error) {
+	// consecutive definitions share the bound before the first of them
+	startBounds := make(map[*tokenization.Token]*tokenization.Token)
+
+	for _, t := range tokens.Tokens {
+		if t.Type != tokenization.TokenTypeSquareBracketOpen {
+			continue
+		}
+
+		footnoteLabel, ok := compileTokenizeFootnotesFindLabel(t)
+		if !ok {
+			continue
+		}
+
+		if colonToken := footnoteLabel.closeToken.Next(); colonToken != nil && colonToken.Type == tokenization.TokenTypeTextGroup &&
+			colonToken.ListCollection.Input[colonToken.InputStartIndex] == ':' {
+			if compileTokenizeFootnotesDefinition(t, footnoteLabel, colonToken, startBounds) {
+				continue
+			}
+		}
+
+		for t2 := t.RawNext; t2 != nil && t2 != footnoteLabel.closeToken.RawNext; t2 = t2.RawNext {
+			t2.Type = tokenization.TokenTypeEmpty
+		}
+
+		t.Type = tokenization.TokenTypeFootnoteReference
+		t.InputEndIndex = footnoteLabel.closeToken.InputEndIndex
+		t.Attributes = map[string]string{"label": footnoteLabel.label}
+	}
+
+	return
+}
+
+func compileTokenizeFootnotesFindLabel(t *tokenization.Token) (footnoteLabel compileTokenizeFootnoteLabel, ok bool) {
+	first := t.Next()
+	if first == nil || first.Type != tokenization.TokenTypeTextGroup || first.InputStartIndex != t.InputEndIndex ||
+		first.ListCollection.Input[first.InputStartIndex] != '^' {
+		return
+	}
+
+	for t2 := first; t2 != nil; t2 = t2.Next() {
+		switch t2.Type {
+		case tokenization.TokenTypeTextGroup,
+			tokenization.TokenTypeHyphen,
+			tokenization.TokenTypeUnderscore:
+			continue
+		case tokenization.TokenTypeSquareBracketClose:
+			if t2.InputStartIndex == first.InputEndIndex && first.Len() == 1 {
+				return
+			}
+
+			footnoteLabel.label = string(t.ListCollection.Input[first.InputStartIndex+1 : t2.InputStartIndex])
+			footnoteLabel.closeToken = t2
+			ok = true
+		}
+
+		return
+	}
+
+	return
+}
+
+func compileTokenizeFootnotesDefinition(
+	t *tokenization.Token,
+	footnoteLabel compileTokenizeFootnoteLabel,
+	colonToken *tokenization.Token,
+	startBounds map[*tokenization.Token]*tokenization.Token,
+) bool {
+	startBound := t.Prev()
+	if prevStartBound, ok := startBounds[startBound]; ok {
+		startBound = prevStartBound
+	}
+
+	if startBound == nil ||
+		(startBound.Type != tokenization.TokenTypeParagraphBound && startBound.Type != tokenization.TokenTypeLineBreak) {
+		return false
+	}
+
+	endBound := t.NextOfTypes(
+		tokenization.TokenTypeParagraphBound,
+		tokenization.TokenTypeLineBreak,
+	)
+	if endBound == nil {
+		return false
+	}
+
+	for t2 := t.RawNext; t2 != nil && t2 != colonToken; t2 = t2.RawNext {
+		t2.Type = tokenization.TokenTypeEmpty
+	}
+
+	if colonToken.InputStartIndex++; colonToken.Len() == 0 {
+		colonToken.Type = tokenization.TokenTypeEmpty
+	}
+
+	if space := colonToken.Next(); space != nil && space.Type == tokenization.TokenTypeSpaceGroup {
+		space.Type = tokenization.TokenTypeEmpty
+	}
+
+	t.Type = tokenization.TokenTypeFootnoteDefinitionBound
+	t.Attributes = map[string]string{"label": footnoteLabel.label}
+
+	closeBound := endBound.ListCollection.InsertNewEmptyBefore(endBound, tokenization.TokenTypeFootnoteDefinitionBound)
+	startBounds[closeBound] = startBound
+
+	compileTokenizeDetachLine(startBound, endBound)
+
+	return true
+}
+
+type compileTokenizeLinkDefinition struct {
+	url      string
+	title    string
+	hasTitle bool
+}
+
+var (
+	compileTokenizeLinkDefinitionRegexp = regexp.MustCompile(
+		`^\[((?:[^\[\]\\]|\\.)+)\]:[ \t]*(\S+)(?:[ \t]+("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'))?[ \t]*$`,
+	)
+)
+
+// Definitions must sit on a line of their own, and are removed from the
+// output along with the line break that ends them.
+func compileTokenizeLinkDefinitions(
+	tokens *tokenization.TokenSliceCollection,
+	definitions map[string]compileTokenizeLinkDefinition,
+	options *Options,
+) {
+	for _, t := range tokens.Tokens {
+		if t.Type != tokenization.TokenTypeSquareBracketOpen {
+			continue
+		}
+
+		firstToken := t
+		startBound := t.Prev()
+
+		if startBound != nil && startBound.Type == tokenization.TokenTypeSpaceGroup && startBound.Len() <= 3 {
+			firstToken = startBound
+			startBound = startBound.Prev()
+		}
+
+		if startBound == nil ||
+			(startBound.Type != tokenization.TokenTypeParagraphBound && startBound.Type != tokenization.TokenTypeLineBreak) {
+			continue
+		}
+
+		endBound := t.NextOfTypes(
+			tokenization.TokenTypeParagraphBound,
+			tokenization.TokenTypeLineBreak,
 		)
-		if !foundLinkTokens {
+		if endBound == nil {
 			continue
 		}
 
-		lastLinkToken := linkTokens.Get(-1)
-		var finalToken *tokenization.Token
-		var titleTokens *tokenization.TokenSliceCollection
+		input := t.ListCollection.Input
+		lineEndIndex := endBound.InputStartIndex
+		if lineEndIndex <= t.InputStartIndex {
+			lineEndIndex = len(input)
+		}
 
-		spaceTokens, foundSpaceTokens := lastLinkToken.NextsCollectionUntilEndOfPotentialTypes(
-			tokenization.TokenTypeSpaceGroup,
-		)
-		if foundSpaceTokens {
-			var foundTitleTokens bool
-			titleTokens, foundTitleTokens = spaceTokens.Get(-1).NextsCollectionUntilEndOfPotentialTypes(
-				tokenization.TokenTypeListImageSegmentTitle...,
-			)
-			if !foundTitleTokens {
-				continue
-			}
+		line := bytes.TrimRight(input[t.InputStartIndex:lineEndIndex], "\r")
 
-			finalToken = titleTokens.Get(-1).Next()
-		} else {
-			finalToken = lastLinkToken.Next()
+		match := compileTokenizeLinkDefinitionRegexp.FindSubmatch(line)
+		if match == nil {
+			continue
 		}
 
-		if finalToken == nil || finalToken.Type != tokenization.TokenTypeParenthesisClose {
+		// a label that starts with a caret belongs to a footnote
+		if options.EnableFootnotes && match[1][0] == '^' {
 			continue
 		}
 
-		var linkBuff, textBuff bytes.Buffer
+		label := compileTokenizeLinksNormalizeLabel(string(match[1]))
+		if label == "" {
+			continue
+		}
 
-		for _, t2 := range linkTokens.Tokens {
-			linkBuff.Write(t2.Bytes())
+		if _, ok := definitions[label]; !ok {
+			definition := compileTokenizeLinkDefinition{
+				url: compileTokenizeUnescapeString(string(match[2]), options),
+			}
+
+			if title := match[3]; len(title) > 0 {
+				definition.title = compileTokenizeUnescapeString(string(title[1:len(title)-1]), options)
+				definition.hasTitle = true
+			}
+
+			definitions[label] = definition
 		}
-		for _, t2 := range textTokens.Tokens {
-			textBuff.Write(t2.Bytes())
+
+		for t2 := firstToken; t2 != nil && t2 != endBound; t2 = t2.RawNext {
+			t2.Type = tokenization.TokenTypeEmpty
 		}
 
-		linkString := linkBuff.String()
-		textString := textBuff.String()
+		compileTokenizeDetachLine(startBound, endBound)
+	}
+}
 
-		var linkURL *url.URL
-		linkURL, err = url.Parse(linkString)
-		if err != nil {
-			continue
+// The bounds around a line that has been taken out of the flow are removed,
+// along with its paragraph when the line was the only thing in it.
+func compileTokenizeDetachLine(startBound, endBound *tokenization.Token) {
+	if endBound.Type == tokenization.TokenTypeLineBreak {
+		endBound.Type = tokenization.TokenTypeEmpty
+
+		if endBound = endBound.Next(); endBound == nil || endBound.Type != tokenization.TokenTypeParagraphBound {
+			return
 		}
+	}
 
-		linkString = linkURL.String()
-		textString = html.EscapeString(textString)
+	if startBound.Type == tokenization.TokenTypeParagraphBound {
+		endBound.Type = tokenization.TokenTypeEmpty
+	}
 
-		t.Type = tokenization.TokenTypeImageBound
-		t.Attributes = map[string]string{
-			"alt": textString,
-			"src": linkString,
+	startBound.Type = tokenization.TokenTypeEmpty
+}
+
+func compileTokenizeLinksReference(t *tokenization.Token, definitions map[string]compileTokenizeLinkDefinition, options *Options) {
+	textTokens, foundTextTokens := t.NextsCollectionUntilEndOfPotentialTypes(
+		tokenization.TokenTypeListLinkSegmentText...,
+	)
+	if !foundTextTokens {
+		return
+	}
+
+	closeToken := textTokens.Get(-1).Next()
+	if closeToken == nil || closeToken.Type != tokenization.TokenTypeSquareBracketClose {
+		return
+	}
+
+	input := t.ListCollection.Input
+	label := string(input[textTokens.Get(0).InputStartIndex:textTokens.Get(-1).InputEndIndex])
+	labelTokens := tokenization.TokenSliceCollectionNew()
+
+	if labelOpenToken := closeToken.Next(); labelOpenToken != nil && labelOpenToken.Type == tokenization.TokenTypeSquareBracketOpen {
+		labelTextTokens, foundLabelTextTokens := labelOpenToken.NextsCollectionUntilEndOfPotentialTypes(
+			tokenization.TokenTypeListLinkSegmentText...,
+		)
+
+		labelCloseToken := labelOpenToken.Next()
+		if foundLabelTextTokens {
+			labelCloseToken = labelTextTokens.Get(-1).Next()
 		}
 
-		if foundSpaceTokens {
-			var titleBuff bytes.Buffer
+		if labelCloseToken != nil && labelCloseToken.Type == tokenization.TokenTypeSquareBracketClose {
+			labelTokens.Push(labelOpenToken, labelCloseToken)
 
-			for _, t2 := range titleTokens.Tokens {
-				titleBuff.Write(t2.Bytes())
+			if foundLabelTextTokens {
+				labelTokens.Push(labelTextTokens.Tokens...)
+				label = string(input[labelTextTokens.Get(0).InputStartIndex:labelTextTokens.Get(-1).InputEndIndex])
+			}
+		}
+	}
+
+	definition, ok := definitions[compileTokenizeLinksNormalizeLabel(label)]
+	if !ok {
+		return
+	}
+
+	linkString, ok := compileTokenizeLinksURL(definition.url)
+	if !ok {
+		return
+	}
+
+	if !compileURLIsAllowed(linkString, options) {
+		if !options.RenderUnsafeURLsAsText {
+			t.Type = tokenization.TokenTypeEmpty
+			closeToken.Type = tokenization.TokenTypeEmpty
+			labelTokens.SetAllTokenTypesToEmpty()
+
+			if exclamationToken := t.Prev(); exclamationToken != nil && exclamationToken.Type == tokenization.TokenTypeExclamation && options.EnableImages {
+				exclamationToken.Type = tokenization.TokenTypeEmpty
 			}
+		}
+
+		return
+	}
 
-			titleString := titleBuff.String()
+	labelTokens.SetAllTokenTypesToEmpty()
 
-			t.Attributes["title"] = titleString
+	if exclamationToken := t.Prev(); exclamationToken != nil && exclamationToken.Type == tokenization.TokenTypeExclamation && options.EnableImages {
+		var textBuff bytes.Buffer
 
-			spaceTokens.SetAllTokenTypesToEmpty()
-			titleTokens.SetAllTokenTypesToEmpty()
+		for _, t2 := range textTokens.Tokens {
+			textBuff.Write(compileTokenizeUnescapedBytes(t2))
+		}
+
+		exclamationToken.Type = tokenization.TokenTypeImageBound
+		exclamationToken.Attributes = map[string]string{
+			"alt": textBuff.String(),
+			"src": linkString,
+		}
+
+		if definition.hasTitle {
+			exclamationToken.Attributes["title"] = definition.title
 		}
 
 		textTokens.SetAllTokenTypesToEmpty()
-		midTokens.SetAllTokenTypesToEmpty()
-		linkTokens.SetAllTokenTypesToEmpty()
+		t.Type = tokenization.TokenTypeImageBound
+		closeToken.Type = tokenization.TokenTypeEmpty
 
-		squareBracketOpenToken.Type = tokenization.TokenTypeImageBound
+		return
+	}
 
-		finalToken.Type = tokenization.TokenTypeEmpty
+	t.Type = tokenization.TokenTypeLinkBound
+	t.Attributes = map[string]string{"href": linkString}
+
+	if definition.hasTitle {
+		t.Attributes["title"] = definition.title
+	}
+
+	closeToken.Type = tokenization.TokenTypeLinkBound
+}
+
+func compileTokenizeLinksURL(rawURL string) (linkString string, ok bool) {
+	linkURL, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+
+	linkString = linkURL.String()
+
+	if compileTokenizeLinksEmailRegexp.MatchString(linkString) {
+		linkString = "mailto:" + linkString
 	}
 
+	ok = true
+
 	return
 }
 
+func compileTokenizeLinksNormalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+func compileTokenizeTrimTitleQuotes(title string) string {
+	if l := len(title); l >= 2 {
+		switch first, last := title[0], title[l-1]; {
+		case first == '"' && last == '"',
+			first == '\'' && last == '\'':
+			return title[1 : l-1]
+		}
+	}
+
+	return title
+}
+
+const (
+	compileTokenizeAutolinkBoundaryBytes    = " \t\r\n*_~("
+	compileTokenizeAutolinkTrailingBytes    = "?!.,:;*_~'\""
+	compileTokenizeAutolinkEmailLocalBytes  = ".+-_"
+	compileTokenizeAutolinkWWWPrefix        = "www."
+	compileTokenizeAutolinkWWWDefaultScheme = "http://"
+)
+
 var (
-	compileTokenizeLinksEmailRegexp = regexp.MustCompile(
-		"^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$",
-	)
+	compileTokenizeAutolinkPrefixes = []string{compileTokenizeAutolinkWWWPrefix, "https://", "http://"}
 )
 
-func compileTokenizeLinks(tokens *tokenization.TokenSliceCollection) (err error) {
-	for _, t := range tokens.Tokens {
-		var textTokens, midTokens, linkTokens, spaceTokens, titleTokens *tokenization.TokenSliceCollection
-		var foundTextTokens, foundMidTokens, foundLinkTokens, foundSpaceTokens bool
-		var finalToken *tokenization.Token
-		var expectedFinalTokenType tokenization.TokenType
+// Like the autolinks of GitHub Flavored Markdown, a bare address must start
+// a line or follow whitespace, an opening parenthesis or an emphasis delimiter.
+func compileTokenizeIsAutolinkBoundary(input []byte, i int) bool {
+	if !compileHTMLIsLetter(input[i]) && !compileHTMLIsDigit(input[i]) {
+		return false
+	}
 
-		switch t.Type {
-		case tokenization.TokenTypeSquareBracketOpen:
-			textTokens, foundTextTokens = t.NextsCollectionUntilEndOfPotentialTypes(
-				tokenization.TokenTypeListLinkSegmentText...,
-			)
-			if !foundTextTokens {
-				continue
-			}
+	return i == 0 || strings.IndexByte(compileTokenizeAutolinkBoundaryBytes, input[i-1]) >= 0
+}
 
-			midTokens, foundMidTokens = textTokens.Get(-1).NextNTypesCollection([]tokenization.TokenType{
-				tokenization.TokenTypeSquareBracketClose,
-				tokenization.TokenTypeParenthesisOpen,
-			})
-			if !foundMidTokens {
-				continue
-			}
+func compileTokenizeFindAutolink(input []byte, i int) (endIndex int, ok bool) {
+	var prefix string
 
-			linkTokens, foundLinkTokens = midTokens.Get(-1).NextsCollectionUntilEndOfPotentialTypes(
-				tokenization.TokenTypeListLinkSegmentLink...,
-			)
-			if !foundLinkTokens {
-				continue
-			}
+	for _, p := range compileTokenizeAutolinkPrefixes {
+		if l := len(p); i+l <= len(input) && strings.EqualFold(string(input[i:i+l]), p) {
+			prefix = p
+			break
+		}
+	}
 
-			expectedFinalTokenType = tokenization.TokenTypeParenthesisClose
-		case tokenization.TokenTypeAngleBracketOpen:
-			linkTokens, foundLinkTokens = t.NextsCollectionUntilEndOfPotentialTypes(
-				tokenization.TokenTypeListLinkSegmentLink...,
-			)
-			if !foundLinkTokens {
-				continue
-			}
+	if prefix == "" {
+		return compileTokenizeFindAutolinkEmail(input, i)
+	}
 
-			expectedFinalTokenType = tokenization.TokenTypeAngleBracketClose
-		default:
-			continue
-		}
+	domainStartIndex := i
+	if prefix != compileTokenizeAutolinkWWWPrefix {
+		domainStartIndex += len(prefix)
+	}
 
-		{
-			lastLinkToken := linkTokens.Get(-1)
-			spaceTokens, foundSpaceTokens = lastLinkToken.NextsCollectionUntilEndOfPotentialTypes(
-				tokenization.TokenTypeSpaceGroup,
-			)
-			if foundSpaceTokens {
-				var foundTitleTokens bool
-				titleTokens, foundTitleTokens = spaceTokens.Get(-1).NextsCollectionUntilEndOfPotentialTypes(
-					tokenization.TokenTypeListLinkSegmentTitle...,
-				)
-				if !foundTitleTokens {
-					continue
-				}
+	endIndex = domainStartIndex
+	for endIndex < len(input) && !compileTokenizeIsAutolinkEnd(input[endIndex]) {
+		endIndex++
+	}
 
-				finalToken = titleTokens.Get(-1).Next()
-			} else {
-				finalToken = lastLinkToken.Next()
-			}
+	endIndex = compileTokenizeTrimAutolink(input, i, endIndex)
 
-			if finalToken == nil || finalToken.Type != expectedFinalTokenType {
-				continue
-			}
-		}
+	domainEndIndex := domainStartIndex
+	for domainEndIndex < endIndex && compileTokenizeIsAutolinkDomainByte(input[domainEndIndex]) {
+		domainEndIndex++
+	}
 
-		var linkBuff bytes.Buffer
+	ok = compileTokenizeIsAutolinkDomain(input[domainStartIndex:domainEndIndex])
 
-		for _, t2 := range linkTokens.Tokens {
-			linkBuff.Write(t2.Bytes())
-		}
+	return
+}
 
-		linkString := linkBuff.String()
+func compileTokenizeFindAutolinkEmail(input []byte, i int) (endIndex int, ok bool) {
+	l := len(input)
 
-		linkURL, err2 := url.Parse(linkString)
-		if err2 != nil {
-			continue
+	j := i
+	for j < l && (compileHTMLIsLetter(input[j]) || compileHTMLIsDigit(input[j]) ||
+		strings.IndexByte(compileTokenizeAutolinkEmailLocalBytes, input[j]) >= 0) {
+		j++
+	}
+
+	if j >= l || input[j] != '@' {
+		return
+	}
+
+	domainStartIndex := j + 1
+
+	endIndex = domainStartIndex
+	for endIndex < l && compileTokenizeIsAutolinkDomainByte(input[endIndex]) {
+		endIndex++
+	}
+
+	for endIndex > domainStartIndex && input[endIndex-1] == '.' {
+		endIndex--
+	}
+
+	domain := input[domainStartIndex:endIndex]
+	if len(domain) == 0 || !bytes.Contains(domain, []byte{'.'}) {
+		return
+	}
+
+	if last := domain[len(domain)-1]; last == '-' || last == '_' {
+		return
+	}
+
+	ok = true
+
+	return
+}
+
+// Backticks, pipes and square brackets end an address, so that it never
+// runs into a code span, a table cell or the text of a link.
+func compileTokenizeIsAutolinkEnd(b byte) bool {
+	switch b {
+	case ' ', '\t', '\r', '\n', '<', '`', '|', '[', ']':
+		return true
+	}
+
+	return false
+}
+
+func compileTokenizeIsAutolinkDomainByte(b byte) bool {
+	return compileHTMLIsLetter(b) || compileHTMLIsDigit(b) || b == '-' || b == '_' || b == '.'
+}
+
+// A domain needs at least two segments, and there must be no underscores
+// in the last two of them.
+func compileTokenizeIsAutolinkDomain(domain []byte) bool {
+	segments := bytes.Split(domain, []byte{'.'})
+
+	l := len(segments)
+	if l < 2 {
+		return false
+	}
+
+	for i, s := range segments {
+		if len(s) == 0 && i < l-1 {
+			return false
 		}
-		linkString = linkURL.String()
 
-		if compileTokenizeLinksEmailRegexp.MatchString(linkString) {
-			linkString = "mailto:" + linkString
+		if i >= l-2 && bytes.IndexByte(s, '_') >= 0 {
+			return false
 		}
+	}
 
-		t.Type = tokenization.TokenTypeLinkBound
-		t.Attributes = map[string]string{"href": linkString}
+	return len(segments[l-1]) > 0
+}
 
-		if foundSpaceTokens {
-			var titleBuff bytes.Buffer
+// Trailing punctuation is left out of an address, as is a closing
+// parenthesis without a partner inside it.
+func compileTokenizeTrimAutolink(input []byte, startIndex, endIndex int) int {
+	for endIndex > startIndex {
+		b := input[endIndex-1]
 
-			for _, t2 := range titleTokens.Tokens {
-				titleBuff.Write(t2.Bytes())
+		if strings.IndexByte(compileTokenizeAutolinkTrailingBytes, b) >= 0 {
+			endIndex--
+			continue
+		}
+
+		if b == ')' {
+			link := input[startIndex:endIndex]
+			if bytes.Count(link, []byte{')'}) > bytes.Count(link, []byte{'('}) {
+				endIndex--
+				continue
 			}
+		}
 
-			titleString := titleBuff.String()
+		break
+	}
 
-			t.Attributes["title"] = titleString
+	return endIndex
+}
 
-			spaceTokens.SetAllTokenTypesToEmpty()
-			titleTokens.SetAllTokenTypesToEmpty()
+func compileTokenizeAutolinks(tokens *tokenization.TokenSliceCollection, options *Options) (err error) {
+	for _, t := range tokens.Tokens {
+		// the address may have become the destination of a link or part of a link definition
+		if t.Type != tokenization.TokenTypeTextGroup || compileTokenizeIsInsideLink(t) {
+			continue
 		}
 
-		if foundTextTokens {
-			textTokens.SetAllTokenTypesToEmpty()
+		rawURL := t.String()
+		if strings.HasPrefix(strings.ToLower(rawURL), compileTokenizeAutolinkWWWPrefix) {
+			rawURL = compileTokenizeAutolinkWWWDefaultScheme + rawURL
 		}
 
-		if foundMidTokens {
-			midTokens.SetAllTokenTypesToEmpty()
+		linkString, ok := compileTokenizeLinksURL(rawURL)
+		if !ok || !compileURLIsAllowed(linkString, options) {
+			continue
 		}
 
-		finalToken.Type = tokenization.TokenTypeLinkBound
+		openBound := t.ListCollection.InsertNewEmptyBefore(t, tokenization.TokenTypeLinkBound)
+		openBound.Attributes = map[string]string{"href": linkString}
+
+		t.ListCollection.InsertNewEmptyAfter(t, tokenization.TokenTypeLinkBound)
 	}
 
 	return
 }
 
+// Links never span lines, so the bounds before a token on its line tell
+// whether it lies inside one.
+func compileTokenizeIsInsideLink(t *tokenization.Token) bool {
+	var isInside bool
+
+	for t2 := t.Prev(); t2 != nil; t2 = t2.Prev() {
+		switch t2.Type {
+		case tokenization.TokenTypeLinkBound:
+			isInside = !isInside
+		case tokenization.TokenTypeStart,
+			tokenization.TokenTypeParagraphBound,
+			tokenization.TokenTypeLineBreak:
+			return isInside
+		}
+	}
+
+	return isInside
+}
+
 func compileTokenizeBlockquotes(tokens *tokenization.TokenSliceCollection) (err error) {
 	for _, t := range tokens.Tokens {
 		prevBound := t.Prev()
@@ -916,468 +3094,266 @@ func compileTokenizeHeadings(tokens *tokenization.TokenSliceCollection) (err err
 	return
 }
 
-func compileTokenizeHyphenTransforms(tokens *tokenization.TokenSliceCollection) (err error) {
-	for _, t := range tokens.Tokens {
-		if next := t.Next(); next != nil && next.Type == tokenization.TokenTypeSpaceGroup {
-			next.Type = tokenization.TokenTypeSpaceHair
-		}
-
-		if prev := t.Prev(); prev != nil && prev.Type == tokenization.TokenTypeSpaceGroup {
-			prev.Type = tokenization.TokenTypeSpaceHair
-		}
-
-		switch t.Type {
-		case tokenization.TokenTypeHyphenTriple:
-			t.Type = tokenization.TokenTypeDashEm
-		case tokenization.TokenTypeHyphenDouble:
-			t.Type = tokenization.TokenTypeDashEn
-		}
-	}
-
-	return
-}
+// Delimiters pair up in order, like emphasis, but an opener must be followed
+// and a closer preceded by something other than whitespace, and subscripts and
+// superscripts may not contain whitespace at all; the rest are left as text.
+func compileTokenizeDelimiters(tokens *tokenization.TokenSliceCollection) (err error) {
+	openers := make(map[tokenization.TokenType]*tokenization.Token)
 
-func compileTokenizeBackslashTransforms(tokens *tokenization.TokenSliceCollection) (err error) {
 	for _, t := range tokens.Tokens {
-		var isHandled bool
-
-		if nextText := t.Next(); nextText != nil && nextText.Type == tokenization.TokenTypeTextGroup {
-			if nextText.Len() > 0 {
-				switch isHandled = true; nextText.ListCollection.Input[nextText.InputStartIndex] {
-				case 'n':
-					t.Type = tokenization.TokenTypeLineBreak
-					compileTokenizeTransformNewLineBreak(t)
-				case 'r':
-					t.Type = tokenization.TokenTypeCarriageReturn
-				case 't':
-					t.Type = tokenization.TokenTypeTabGroup
-				case '\\':
-					break
-				default:
-					isHandled = false
-				}
+		y := t.Type
 
-				if isHandled {
-					if nextText.InputStartIndex++; nextText.Len() == 0 {
-						nextText.Type = tokenization.TokenTypeEmpty
-					}
-				}
-			}
+		if opener, ok := openers[y]; ok && compileTokenizeDelimiterCanClose(opener, t) {
+			delete(openers, y)
+			continue
 		}
 
-		if !isHandled {
-			err = ErrCompileBackslashTransformUnknown
-			return
+		if compileTokenizeIsWhitespace(t.Next()) {
+			t.Type = tokenization.TokenTypeTextGroup
+			continue
 		}
-	}
-
-	return
-}
 
-func compileCleanEmptyTokens(tokens *tokenization.TokenListCollection) {
-	var n *tokenization.Token
-
-	for t := tokens.HeadToken; t != nil; t = n {
-		n = t.RawNext
-
-		if t.Type == tokenization.TokenTypeEmpty {
-			tokens.Remove(t)
+		if opener, ok := openers[y]; ok {
+			opener.Type = tokenization.TokenTypeTextGroup
 		}
-	}
-}
 
-func compileGenerateHTML(options *Options, tokens *tokenization.TokenListCollection) (err error) {
-	tokenStack := tokenization.TokenSliceCollectionNew()
-
-	for t := tokens.HeadToken; t != nil; t = t.RawNext {
-		if err = compileGenerateHTMLToken(options, t, tokenStack); err != nil {
-			return
-		}
+		openers[y] = t
 	}
 
-	if tokenStack.Len() > 0 {
-		for {
-			if t := tokenStack.Pop(); t != nil {
-				t.Type = tokenization.TokenTypeTextGroup
-				if err = compileGenerateHTMLToken(options, t, nil); err != nil {
-					return
-				}
-			} else {
-				break
-			}
-		}
+	for _, t := range openers {
+		t.Type = tokenization.TokenTypeTextGroup
 	}
 
 	return
 }
 
-func compileGenerateHTMLToken(options *Options, t *tokenization.Token, tokenStack *tokenization.TokenSliceCollection) (err error) {
-	switch y := t.Type; y {
-	case tokenization.TokenTypeEmpty,
-		tokenization.TokenTypeStart,
-		tokenization.TokenTypeEnd:
-		t.HTML = []byte{}
-	case tokenization.TokenTypeTextGroup:
-		compileGenerateHTMLTokenHandleBytes(t)
-
-		if !options.AllowHTML {
-			t.HTML = []byte(html.EscapeString(string(t.HTML)))
-		}
-	case tokenization.TokenTypeSpaceGroup:
-		l := t.Len()
-		t.HTML = make([]byte, l)
+func compileTokenizeDelimiterCanClose(opener, t *tokenization.Token) bool {
+	if prev := t.Prev(); prev == opener || compileTokenizeIsWhitespace(prev) {
+		return false
+	}
 
-		for i := 0; i < l; i++ {
-			t.HTML[i] = ' '
-		}
-	case tokenization.TokenTypeDocumentBodyBound,
-		tokenization.TokenTypeDocumentHeadBound,
-		tokenization.TokenTypeDocumentHTMLBound,
-		tokenization.TokenTypeHeading1Bound,
-		tokenization.TokenTypeHeading2Bound,
-		tokenization.TokenTypeHeading3Bound,
-		tokenization.TokenTypeHeading4Bound,
-		tokenization.TokenTypeHeading5Bound,
-		tokenization.TokenTypeHeading6Bound,
-		tokenization.TokenTypeBlockquoteBound:
-		err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options)
-	case tokenization.TokenTypeParagraphBound:
-		if options.EnableHorizontalRules {
-			if next := t.Next(); next != nil {
-				if next.Type == tokenization.TokenTypeAsteriskTriple ||
-					next.Type == tokenization.TokenTypeUnderscoreTriple {
-					if nextNext := next.Next(); nextNext != nil {
-						if nextNext.Type == tokenization.TokenTypeParagraphBound {
-							t.Type = tokenization.TokenTypeEmpty
-							next.Type = tokenization.TokenTypeHorizontalRule
-							nextNext.Type = tokenization.TokenTypeEmpty
-							break
-						}
-					}
-				}
+	switch t.Type {
+	case tokenization.TokenTypeTilde, tokenization.TokenTypeCaret:
+		for t2 := opener.RawNext; t2 != nil && t2 != t; t2 = t2.RawNext {
+			if compileTokenizeIsWhitespace(t2) {
+				return false
 			}
 		}
+	}
 
-		if !options.EnableParagraphs {
-			if prev := t.Prev(); prev != nil {
-				if next := t.Next(); next != nil {
-					t.HTML = []byte{'\n'}
-					break
-				}
-			}
-		}
+	return true
+}
 
-		err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options)
-	case tokenization.TokenTypeLineBreak:
-		if !options.EnableParagraphs {
-			t.HTML = []byte{'\n'}
-			break
-		}
+func compileTokenizeIsWhitespace(t *tokenization.Token) bool {
+	if t == nil {
+		return true
+	}
 
-		err = compileGenerateHTMLTokenHandleTagFromSingleToken(t, tokenStack, options)
-	case tokenization.TokenTypeBackslash,
-		tokenization.TokenTypeParenthesisOpen,
-		tokenization.TokenTypeParenthesisClose,
-		tokenization.TokenTypeSquareBracketOpen,
-		tokenization.TokenTypeSquareBracketClose,
-		tokenization.TokenTypeExclamation,
-		tokenization.TokenTypeHash,
-		tokenization.TokenTypeHashDouble,
-		tokenization.TokenTypeHashTriple,
-		tokenization.TokenTypeHashQuadruple,
-		tokenization.TokenTypeHashQuintuple,
-		tokenization.TokenTypeHashSextuple,
-		tokenization.TokenTypeHyphen,
-		tokenization.TokenTypeHyphenDouble,
-		tokenization.TokenTypeHyphenTriple:
-		compileGenerateHTMLTokenHandleBytes(t)
-	case tokenization.TokenTypeAngleBracketOpen:
-		t.HTML = []byte{'&', 'l', 't', ';'}
-	case tokenization.TokenTypeAngleBracketClose:
-		t.HTML = []byte{'&', 'g', 't', ';'}
-	case tokenization.TokenTypeDashEm:
-		t.HTML = []byte("—")
-	case tokenization.TokenTypeDashEn:
-		t.HTML = []byte("–")
-	case tokenization.TokenTypeTabGroup:
-		t.HTML = []byte{}
-
-		for i := t.Len(); i > 0; i-- {
-			t.HTML = append(t.HTML, '\t')
-		}
-	case tokenization.TokenTypeCarriageReturn:
-		t.HTML = []byte{}
+	switch t.Type {
+	case tokenization.TokenTypeStart,
+		tokenization.TokenTypeEnd,
+		tokenization.TokenTypeParagraphBound,
+		tokenization.TokenTypeLineBreak,
+		tokenization.TokenTypeCarriageReturn,
+		tokenization.TokenTypeSpaceGroup,
+		tokenization.TokenTypeSpaceHair,
+		tokenization.TokenTypeTabGroup:
+		return true
+	}
 
-		if _, foundNewline := t.NextsCollectionUntilEndOfPotentialTypes(
-			tokenization.TokenTypeParagraphBound,
-			tokenization.TokenTypeLineBreak,
-		); !foundNewline {
-			prevs, foundPrevs := t.PrevsCollectionUntilStartOfPotentialTypes(
-				tokenization.TokenTypeParagraphBound,
-			)
-			if foundPrevs {
-				for _, t2 := range prevs.Tokens {
-					if t2.Type == tokenization.TokenTypeTextGroup {
-						t2.Type = tokenization.TokenTypeEmpty
-					}
-				}
-			}
-		}
-	case tokenization.TokenTypeBacktickDouble:
-		var insideCode bool
+	return false
+}
 
-		if options.EnableCodeTags {
-			d := tokenStack.Tokens
-			for i := len(d) - 1; i >= 0; i-- {
-				if t2 := d[i]; t2.Type == tokenization.TokenTypeBacktick {
-					insideCode = true
-					break
-				}
+func compileTokenizeHyphenTransforms(tokens *tokenization.TokenSliceCollection, options *Options) (err error) {
+	for _, t := range tokens.Tokens {
+		switch t.Type {
+		case tokenization.TokenTypeHyphen:
+			// the typography pass turns "+-" into a plus-minus sign, which keeps its spaces
+			if options.EnableTypography && compileTokenizeIsAfterPlus(t) {
+				continue
 			}
+		case tokenization.TokenTypeHyphenDouble,
+			tokenization.TokenTypeHyphenTriple:
+			// noop
+		default:
+			continue
 		}
 
-		t.HTML = []byte{'`'}
-
-		if !insideCode {
-			t.HTML = append(t.HTML, '`')
-		}
-	case tokenization.TokenTypeBacktick:
-		if !options.EnableCodeTags {
-			compileGenerateHTMLTokenHandleBytes(t)
-			break
+		if next := t.Next(); next != nil && next.Type == tokenization.TokenTypeSpaceGroup {
+			next.Type = tokenization.TokenTypeSpaceHair
 		}
 
-		if next := t.Next(); next != nil && next.Type == y {
-			compileGenerateHTMLTokenHandleBytes(t)
-			break
+		if prev := t.Prev(); prev != nil && prev.Type == tokenization.TokenTypeSpaceGroup {
+			prev.Type = tokenization.TokenTypeSpaceHair
 		}
 
-		if prev := t.Prev(); prev != nil && prev.Type == y {
-			if prevPrev := prev.Prev(); prevPrev == nil || prevPrev.Type != y {
-				break
-			}
+		switch t.Type {
+		case tokenization.TokenTypeHyphenTriple:
+			t.Type = tokenization.TokenTypeDashEm
+		case tokenization.TokenTypeHyphenDouble:
+			t.Type = tokenization.TokenTypeDashEn
 		}
+	}
 
-		err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options)
-	case tokenization.TokenTypeUnderscoreTriple, tokenization.TokenTypeAsteriskTriple:
-		if options.EnableStrongTags && options.EnableEmTags {
-			err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options)
-			break
-		}
+	return
+}
 
-		fallthrough
-	case tokenization.TokenTypeUnderscoreDouble, tokenization.TokenTypeAsteriskDouble:
-		if !options.EnableStrongTags {
-			compileGenerateHTMLTokenHandleBytes(t)
-			break
-		}
+func compileTokenizeIsAfterPlus(t *tokenization.Token) bool {
+	prev := t.RawPrev
 
-		err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options)
-	case tokenization.TokenTypeUnderscore, tokenization.TokenTypeAsterisk:
-		if !options.EnableEmTags {
-			compileGenerateHTMLTokenHandleBytes(t)
-			break
-		}
+	return prev != nil && prev.Type == tokenization.TokenTypeTextGroup && prev.InputEndIndex == t.InputStartIndex && bytes.HasSuffix(prev.Bytes(), []byte{'+'})
+}
 
-		err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options)
-	case tokenization.TokenTypeSpaceHair:
-		t.HTML = []byte(" ")
-	case tokenization.TokenTypeHorizontalRule:
-		if !options.EnableHorizontalRules {
-			compileGenerateHTMLTokenHandleBytes(t)
-			break
-		}
+const (
+	compileTokenizeLineBreaksMinHardSpaces = 2
+)
 
-		err = compileGenerateHTMLTokenHandleTagFromSingleToken(t, tokenStack, options)
-	case tokenization.TokenTypeEqualsDouble:
-		if !options.EnableMarkTags {
-			compileGenerateHTMLTokenHandleBytes(t)
-			break
+// Once the block passes are done with them, line breaks are only kept as hard
+// breaks after two spaces or a backslash; the others become soft breaks, and
+// any that end a block are dropped.
+func compileTokenizeLineBreaks(tokens *tokenization.TokenSliceCollection) (err error) {
+	for _, t := range tokens.Tokens {
+		if t.Type != tokenization.TokenTypeLineBreak {
+			continue
 		}
 
-		err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options)
-	case tokenization.TokenTypeLinkBound:
-		if !options.EnableLinks {
-			compileGenerateHTMLTokenHandleBytes(t)
-			return
-		}
+		var isHard bool
 
-		err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options)
-	case tokenization.TokenTypeImageBound:
-		if !options.EnableImages {
-			compileGenerateHTMLTokenHandleBytes(t)
-			return
+		prev := t.Prev()
+		if prev != nil && prev.Type == tokenization.TokenTypeCarriageReturn {
+			prev = prev.Prev()
 		}
 
-		err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options)
-	case tokenization.TokenTypeUnorderedListBound,
-		tokenization.TokenTypeListItemBound:
-		if !options.EnableLists {
-			compileGenerateHTMLTokenHandleBytes(t)
-			return
+		if prev != nil {
+			switch prev.Type {
+			case tokenization.TokenTypeSpaceGroup:
+				if prevPrev := prev.Prev(); prevPrev != nil && !compileTokenizeIsLineBreakEnd(prevPrev) {
+					isHard = prev.Len() >= compileTokenizeLineBreaksMinHardSpaces
+					prev.Type = tokenization.TokenTypeEmpty
+				}
+			case tokenization.TokenTypeBackslash:
+				isHard = true
+				prev.Type = tokenization.TokenTypeEmpty
+			}
 		}
 
-		err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options)
-	case tokenization.TokenTypeDocumentDoctype:
-		t.Attributes = map[string]string{
-			"html": "",
+		if next := t.Next(); next == nil || compileTokenizeIsLineBreakEnd(next) {
+			t.Type = tokenization.TokenTypeEmpty
+		} else if !isHard {
+			t.Type = tokenization.TokenTypeLineBreakSoft
 		}
-
-		err = compileGenerateHTMLTokenHandleTagFromSingleToken(t, tokenStack, options)
-	default:
-		err = ErrCompileTokenTypeUnknown
 	}
 
 	return
 }
 
-func compileGenerateHTMLTokenHandleBytes(t *tokenization.Token) {
-	t.HTML = t.Bytes()
-}
-
-func compileGenerateHTMLTokenHandleTagFromSingleToken(t *tokenization.Token, tokenStack *tokenization.TokenSliceCollection, options *Options) (err error) {
-	if err = compileGenerateHTMLTokenHandleTag(t, tokenStack, options); err != nil {
-		return
+func compileTokenizeIsLineBreakEnd(t *tokenization.Token) bool {
+	switch t.Type {
+	case tokenization.TokenTypeStart,
+		tokenization.TokenTypeEnd,
+		tokenization.TokenTypeDocumentBodyBound,
+		tokenization.TokenTypeParagraphBound,
+		tokenization.TokenTypeBlockquoteBound,
+		tokenization.TokenTypeHeading1Bound,
+		tokenization.TokenTypeHeading2Bound,
+		tokenization.TokenTypeHeading3Bound,
+		tokenization.TokenTypeHeading4Bound,
+		tokenization.TokenTypeHeading5Bound,
+		tokenization.TokenTypeHeading6Bound,
+		tokenization.TokenTypeLineBreak,
+		tokenization.TokenTypeLineBreakSoft,
+		tokenization.TokenTypeHorizontalRule,
+		tokenization.TokenTypeUnorderedListBound,
+		tokenization.TokenTypeOrderedListBound,
+		tokenization.TokenTypeListItemBound,
+		tokenization.TokenTypeCodeBlockBound,
+		tokenization.TokenTypeTableBound,
+		tokenization.TokenTypeFootnoteDefinitionBound:
+		return true
 	}
 
-	err = compileGenerateHTMLTokenHandleTag(t.SimpleCloneForClosingTag(), tokenStack, options)
-
-	return
+	return false
 }
 
-func compileGenerateHTMLTokenHandleTag(t *tokenization.Token, tokenStack *tokenization.TokenSliceCollection, options *Options) (err error) {
-	y := t.Type
-
-	if y != tokenization.TokenTypeBacktick && tokenStack.ContainsType(tokenization.TokenTypeBacktick) {
-		compileGenerateHTMLTokenHandleBytes(t)
-		return
-	}
-
-	datum := t.TypeDatum()
-
-	if t2 := tokenStack.Peek(); t2 != nil && t2.Type == y && t2.Indent == t.Indent {
-		if t2.Attributes == nil {
-			t2.Attributes = make(map[string]string)
-		}
-		attributes := t2.Attributes
-
-		var tags []string
-		var tagsLen int
-
-		if datum == nil {
-			tags = []string{"span"}
-			tagsLen = 1
-			attributes["class"] = t2.Type.ClassName()
-		} else {
-			tags = append(tags, datum.Tags...)
-			tagsLen = len(tags)
+func compileTokenizeBackslashTransforms(tokens *tokenization.TokenSliceCollection) (err error) {
+	for _, t := range tokens.Tokens {
+		if t.Type != tokenization.TokenTypeBackslash {
+			continue
 		}
 
-		attributesSliceLen := len(attributes)
-		var attributesSlice [][2]string
-
-		if attributesSliceLen > 0 {
-			attributesKeys := make([]string, 0, attributesSliceLen)
-			for k, _ := range attributes {
-				if k != "" {
-					attributesKeys = append(attributesKeys, k)
-				} else {
-					attributesSliceLen--
-				}
-			}
-
-			if attributesSliceLen > 1 {
-				sort.Strings(attributesKeys)
-			}
+		var isHandled bool
 
-			attributesSlice = make([][2]string, attributesSliceLen)
-			for i, k := range attributesKeys {
-				attributesSlice[i] = [2]string{
-					k,
-					attributes[k],
+		if nextText := t.Next(); nextText != nil && nextText.Type == tokenization.TokenTypeTextGroup {
+			if nextText.Len() > 0 {
+				switch isHandled = true; nextText.ListCollection.Input[nextText.InputStartIndex] {
+				case 'n':
+					t.Type = tokenization.TokenTypeLineBreak
+					compileTokenizeTransformNewLineBreak(t)
+				case 'r':
+					t.Type = tokenization.TokenTypeCarriageReturn
+				case 't':
+					t.Type = tokenization.TokenTypeTabGroup
+				case '\\':
+					break
+				default:
+					isHandled = false
 				}
-			}
-		}
 
-		{
-			var buff, buff2 bytes.Buffer
-
-			for i := 0; i < tagsLen; i++ {
-				buff2.WriteByte('<')
-				buff2.WriteString(tags[i])
-				for j := 0; j < attributesSliceLen; j++ {
-					a := attributesSlice[j]
-					if k := a[0]; k != "" {
-						buff2.WriteByte(' ')
-						buff2.WriteString(a[0])
-						if v := a[1]; v != "" {
-							buff2.WriteByte('=')
-							buff2.WriteByte('"')
-							buff2.WriteString(v)
-							buff2.WriteByte('"')
-						}
+				if isHandled {
+					if nextText.InputStartIndex++; nextText.Len() == 0 {
+						nextText.Type = tokenization.TokenTypeEmpty
 					}
 				}
-				buff2.WriteByte('>')
-
-				if !datum.SelfClosing {
-					buff.WriteByte('<')
-					buff.WriteByte('/')
-					buff.WriteString(tags[tagsLen-i-1])
-					buff.WriteByte('>')
-				}
 			}
-
-			t2.HTML = buff2.Bytes()
-			t.HTML = buff.Bytes()
 		}
 
-		if options.CleanEmptyTags {
-			tagPair := [2]*tokenization.Token{t2, t}
-			t.ListCollection.TagPairCleanData = append(t.ListCollection.TagPairCleanData, tagPair)
+		if !isHandled {
+			err = compileErrorNew(ErrCompileBackslashTransformUnknown, "backslash transforms", t)
+			return
 		}
-
-		tokenStack.Pop()
-	} else {
-		tokenStack.Push(t)
 	}
 
 	return
 }
 
-func compileCleanEmptyTags(tokens *tokenization.TokenListCollection) {
-	for _, pair := range tokens.TagPairCleanData {
-		startTagToken, endTagToken := pair[0], pair[1]
+func compileTokenizeIsEscapable(b byte) bool {
+	return (b >= '!' && b <= '/') || (b >= ':' && b <= '@') || (b >= '[' && b <= '`') || (b >= '{' && b <= '~')
+}
 
-		if datum := startTagToken.TypeDatum(); datum != nil && datum.SelfClosing {
-			continue
+func compileTokenizeUnescapeString(str string, options *Options) string {
+	if !options.EnableBackslashEscapes || !strings.Contains(str, "\\") {
+		return str
+	}
+
+	var builder strings.Builder
+
+	for i, l := 0, len(str); i < l; i++ {
+		if str[i] == '\\' && i+1 < l && compileTokenizeIsEscapable(str[i+1]) {
+			i++
 		}
 
-		shouldClean := true
+		builder.WriteByte(str[i])
+	}
 
-		prevs, foundPrevs := endTagToken.PrevsCollectionUntilMeetToken(startTagToken)
-		if foundPrevs {
-			for _, p := range prevs.Tokens {
-				switch p.Type {
-				case tokenization.TokenTypeTextGroup:
-					if p.Len() > 0 {
-						shouldClean = false
-					}
-				}
+	return builder.String()
+}
 
-				if !shouldClean {
-					break
-				}
-			}
-		}
+func compileTokenizeUnescapedBytes(t *tokenization.Token) []byte {
+	if t.Type == tokenization.TokenTypeBackslashEscape {
+		return t.Bytes()[1:]
+	}
 
-		if shouldClean {
-			tt := tokenization.TokenTypeEmpty
+	return t.Bytes()
+}
+
+func compileCleanEmptyTokens(tokens *tokenization.TokenListCollection) {
+	var n *tokenization.Token
 
-			startTagToken.Type = tt
-			endTagToken.Type = tt
+	for t := tokens.HeadToken; t != nil; t = n {
+		n = t.RawNext
 
-			prevs.SetAllTokenTypes(tt)
+		if t.Type == tokenization.TokenTypeEmpty {
+			tokens.Remove(t)
 		}
 	}
 }
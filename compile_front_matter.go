@@ -0,0 +1,367 @@
+package slimdown
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+const (
+	compileFrontMatterTOMLDelimiter = "+++"
+	compileFrontMatterYAMLDelimiter = "---"
+	compileFrontMatterYAMLEnd       = "..."
+)
+
+type compileFrontMatterLine struct {
+	text   string
+	indent int
+}
+
+type compileFrontMatterParser struct {
+	lines       []compileFrontMatterLine
+	frontMatter map[string]interface{}
+}
+
+// Front matter must open on the very first line and be closed by the same
+// delimiter; otherwise the input is left as it is, so that a document that
+// merely starts with a horizontal rule is still compiled as before. Nor is it
+// front matter when a blank line follows the opening delimiter, or when its
+// first line cannot be read, since that is more likely to be prose between rules.
+// Only flat keys are read, with values that are scalars or simple lists;
+// the raw front matter between the delimiters is returned for anything more.
+func compileFrontMatter(input []byte) (frontMatter map[string]interface{}, raw []byte, bodyIndex int) {
+	firstLine, i := compileFrontMatterNextLine(input, 0)
+
+	delimiter, ok := compileFrontMatterDelimiter(firstLine)
+	if !ok {
+		return
+	}
+
+	if secondLine, _ := compileFrontMatterNextLine(input, i); len(bytes.TrimSpace(secondLine)) == 0 {
+		return
+	}
+
+	p := &compileFrontMatterParser{
+		frontMatter: make(map[string]interface{}),
+	}
+
+	for contentIndex := i; i < len(input); {
+		line, next := compileFrontMatterNextLine(input, i)
+
+		text := string(bytes.TrimRight(line, " \t"))
+		if text == delimiter || (delimiter == compileFrontMatterYAMLDelimiter && text == compileFrontMatterYAMLEnd) {
+			if delimiter == compileFrontMatterYAMLDelimiter {
+				ok = p.parseYAML()
+			} else {
+				ok = p.parseTOML()
+			}
+
+			if ok {
+				frontMatter, raw, bodyIndex = p.frontMatter, input[contentIndex:i], next
+			}
+
+			return
+		}
+
+		trimmed := strings.TrimLeft(text, " \t")
+
+		p.lines = append(p.lines, compileFrontMatterLine{
+			text:   trimmed,
+			indent: len(text) - len(trimmed),
+		})
+
+		i = next
+	}
+
+	return
+}
+
+func compileFrontMatterDelimiter(line []byte) (delimiter string, ok bool) {
+	delimiter = string(bytes.TrimRight(line, " \t\r\n"))
+	ok = delimiter == compileFrontMatterYAMLDelimiter || delimiter == compileFrontMatterTOMLDelimiter
+
+	return
+}
+
+func compileFrontMatterNextLine(input []byte, i int) (line []byte, next int) {
+	j := bytes.IndexByte(input[i:], '\n')
+	if j < 0 {
+		line, next = input[i:], len(input)
+	} else {
+		line, next = input[i:i+j], i+j+1
+	}
+
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+
+	return
+}
+
+// Entries that are not flat, such as nested mappings and block scalars, are
+// skipped along with their indented lines. A key with no value may be
+// followed by the items of a simple list, one per line.
+func (p *compileFrontMatterParser) parseYAML() (ok bool) {
+	var listKey string
+	var hasKey bool
+
+	for _, line := range p.lines {
+		if line.text == "" || line.text[0] == '#' {
+			continue
+		}
+
+		isListItem := line.text == "-" || strings.HasPrefix(line.text, "- ")
+
+		if line.indent > 0 || isListItem {
+			if listKey == "" {
+				continue
+			}
+
+			if value, valueOK := compileFrontMatterParseValue(line.text[1:]); isListItem && valueOK && !compileFrontMatterIsYAMLMapping(line.text[1:]) {
+				list, _ := p.frontMatter[listKey].([]interface{})
+				p.frontMatter[listKey] = append(list, value)
+				continue
+			}
+
+			delete(p.frontMatter, listKey)
+			listKey = ""
+
+			continue
+		}
+
+		listKey = ""
+
+		i := strings.Index(line.text, ":")
+		if i <= 0 || (i+1 < len(line.text) && line.text[i+1] != ' ') {
+			if !hasKey {
+				return
+			}
+
+			continue
+		}
+
+		hasKey = true
+
+		key := strings.TrimSpace(line.text[:i])
+
+		value, valueOK := compileFrontMatterParseValue(line.text[i+1:])
+		if !valueOK {
+			continue
+		}
+
+		if text, isString := value.(string); isString && text != "" && strings.ContainsRune("|>", rune(text[0])) {
+			continue
+		}
+
+		p.frontMatter[key] = value
+
+		if value == nil {
+			listKey = key
+		}
+	}
+
+	ok = true
+
+	return
+}
+
+func compileFrontMatterIsYAMLMapping(text string) bool {
+	text = strings.TrimSpace(compileFrontMatterStripComment(text))
+
+	i := compileFrontMatterIndexUnquoted(text, ':')
+
+	return i > 0 && (i+1 == len(text) || text[i+1] == ' ')
+}
+
+// The keys after a table header belong to the table, so they are skipped
+// along with it.
+func (p *compileFrontMatterParser) parseTOML() (ok bool) {
+	var hasKey bool
+
+	for _, line := range p.lines {
+		if line.text == "" || line.text[0] == '#' {
+			continue
+		}
+
+		if line.text[0] == '[' {
+			break
+		}
+
+		i := strings.IndexByte(line.text, '=')
+		if i <= 0 {
+			if !hasKey {
+				return
+			}
+
+			continue
+		}
+
+		hasKey = true
+
+		key := strings.TrimSpace(line.text[:i])
+		if unquoted, err := strconv.Unquote(key); err == nil {
+			key = unquoted
+		}
+
+		if value, valueOK := compileFrontMatterParseValue(line.text[i+1:]); valueOK && value != nil {
+			p.frontMatter[key] = value
+		}
+	}
+
+	ok = true
+
+	return
+}
+
+// A value is a quoted or plain scalar, or a list of them in brackets.
+func compileFrontMatterParseValue(text string) (value interface{}, ok bool) {
+	text = strings.TrimSpace(compileFrontMatterStripComment(text))
+
+	if text == "" || text[0] != '[' {
+		return compileFrontMatterParseScalar(text)
+	}
+
+	if text[len(text)-1] != ']' {
+		return
+	}
+
+	list := make([]interface{}, 0)
+
+	for rest := text[1 : len(text)-1]; strings.TrimSpace(rest) != ""; {
+		item := rest
+		if i := compileFrontMatterIndexUnquoted(rest, ','); i >= 0 {
+			item, rest = rest[:i], rest[i+1:]
+		} else {
+			rest = ""
+		}
+
+		itemValue, itemOK := compileFrontMatterParseScalar(strings.TrimSpace(item))
+		if !itemOK || itemValue == nil {
+			return
+		}
+
+		list = append(list, itemValue)
+	}
+
+	value, ok = list, true
+
+	return
+}
+
+func compileFrontMatterParseScalar(text string) (value interface{}, ok bool) {
+	switch text {
+	case "", "~", "null":
+		ok = true
+		return
+	case "true":
+		value, ok = true, true
+		return
+	case "false":
+		value, ok = false, true
+		return
+	}
+
+	switch text[0] {
+	case '"', '\'':
+		var rest string
+		value, rest, ok = compileFrontMatterUnquote(text)
+		ok = ok && rest == ""
+		return
+	case '[', ']', '{', '}':
+		return
+	}
+
+	if digits := strings.TrimLeft(text, "+-"); digits != "" && compileHTMLIsDigit(digits[0]) {
+		if i, err := strconv.ParseInt(text, 10, 0); err == nil {
+			value, ok = int(i), true
+			return
+		}
+
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			value, ok = f, true
+			return
+		}
+	}
+
+	value, ok = text, true
+
+	return
+}
+
+// The rest of the text after the closing quote is returned for the caller to check.
+// Single quotes are literal, with a doubled quote standing for one.
+func compileFrontMatterUnquote(text string) (value string, rest string, ok bool) {
+	quote := text[0]
+
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			if quote == '\'' {
+				if i+1 < len(text) && text[i+1] == quote {
+					i++
+					continue
+				}
+
+				value, rest, ok = strings.ReplaceAll(text[1:i], "''", "'"), text[i+1:], true
+				return
+			}
+
+			s, err := strconv.Unquote(text[:i+1])
+			if err != nil {
+				return
+			}
+
+			value, rest, ok = s, text[i+1:], true
+			return
+		}
+	}
+
+	return
+}
+
+func compileFrontMatterIndexUnquoted(text string, b byte) int {
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '"', '\'':
+			_, rest, ok := compileFrontMatterUnquote(text[i:])
+			if !ok {
+				return -1
+			}
+
+			i = len(text) - len(rest) - 1
+		case b:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// A comment starts with a hash at the start of the text, or after a space,
+// provided that it is not inside quotes; a quote within a word, as in
+// an apostrophe, does not start a string.
+func compileFrontMatterStripComment(text string) string {
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '"', '\'':
+			if i > 0 && (compileHTMLIsLetter(text[i-1]) || compileHTMLIsDigit(text[i-1])) {
+				continue
+			}
+
+			_, rest, ok := compileFrontMatterUnquote(text[i:])
+			if !ok {
+				return text
+			}
+
+			i = len(text) - len(rest) - 1
+		case '#':
+			if i == 0 || text[i-1] == ' ' || text[i-1] == '\t' {
+				return text[:i]
+			}
+		}
+	}
+
+	return text
+}
@@ -0,0 +1,151 @@
+package slimdown
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileFrontMatter_yaml(t *testing.T) {
+	const input = "---\n" +
+		"title: \"Hello: world\" # comment\n" +
+		"author: Jo's blog\n" +
+		"date: 2024-01-02\n" +
+		"draft: false\n" +
+		"count: 42\n" +
+		"ratio: 1.5\n" +
+		"empty:\n" +
+		"tags: [a, \"b, c\", 3]\n" +
+		"list:\n" +
+		"  - one\n" +
+		"\n" +
+		"  - 'two'\n" +
+		"---\n" +
+		"Body"
+
+	frontMatter, raw, bodyIndex := compileFrontMatter([]byte(input))
+
+	assert.Equal(t, "Body", input[bodyIndex:])
+	assert.Equal(t, input[4:strings.LastIndex(input, "---")], string(raw))
+	assert.Equal(
+		t,
+		map[string]interface{}{
+			"title":  "Hello: world",
+			"author": "Jo's blog",
+			"date":   "2024-01-02",
+			"draft":  false,
+			"count":  42,
+			"ratio":  1.5,
+			"empty":  nil,
+			"tags":   []interface{}{"a", "b, c", 3},
+			"list":   []interface{}{"one", "two"},
+		},
+		frontMatter,
+	)
+}
+
+func TestCompileFrontMatter_toml(t *testing.T) {
+	const input = "+++\n" +
+		"title = \"Hello # world\" # comment\n" +
+		"date = 2024-01-02T10:00:00Z\n" +
+		"tags = [\"a\", 'b']\n" +
+		"count = 1000\n" +
+		"+++\n" +
+		"Body"
+
+	frontMatter, _, bodyIndex := compileFrontMatter([]byte(input))
+
+	assert.Equal(t, "Body", input[bodyIndex:])
+	assert.Equal(
+		t,
+		map[string]interface{}{
+			"title": "Hello # world",
+			"date":  "2024-01-02T10:00:00Z",
+			"tags":  []interface{}{"a", "b"},
+			"count": 1000,
+		},
+		frontMatter,
+	)
+}
+
+func TestCompileFrontMatter_notFrontMatter(t *testing.T) {
+	for _, input := range []string{
+		"Text\n---\ntitle: x\n---",
+		"---\ntitle: x\n\nNever closed.",
+		"----\ntitle: x\n----",
+		"+++\ntitle = 1\n---",
+		"---\n\nIntro paragraph.\n\n---\n\nMore.",
+		"---\nIntro paragraph.\n---\nMore.",
+	} {
+		frontMatter, _, bodyIndex := compileFrontMatter([]byte(input))
+
+		assert.Nil(t, frontMatter, input)
+		assert.Equal(t, 0, bodyIndex, input)
+	}
+}
+
+func TestCompileFrontMatter_invalid(t *testing.T) {
+	for _, input := range []string{
+		"---\ntitle: x\nauthor:\n  name: Jo\ndescription: >\n  folded\n  text\n---\nBody",
+		"---\ntitle: x\n  bad: y\nlist:\n  - one\n  - key: value\n---\nBody",
+		"+++\ntitle = \"x\"\n[params]\nname = \"Jo\"\n+++\nBody",
+	} {
+		options := DefaultOptions
+		options.EnableFrontMatter = true
+
+		doc, err := ParseString(input, &options)
+		if err != nil {
+			panic(err)
+		}
+
+		output, err := Render(doc, &options)
+		if err != nil {
+			panic(err)
+		}
+
+		// the entries that cannot be read are left to be decoded from the raw front matter
+		assert.Equal(t, "<p>Body</p>", string(output), input)
+		assert.Equal(t, map[string]interface{}{"title": "x"}, doc.FrontMatter, input)
+		assert.Equal(t, input[4:strings.LastIndex(input, "\n")-3], string(doc.RawFrontMatter), input)
+	}
+}
+
+func TestParse_frontMatter(t *testing.T) {
+	const input = "---\ntitle: Post\ntags:\n  - news\n---\n# Heading\n\nText with \\q."
+
+	options := DefaultOptions
+	options.EnableFrontMatter = true
+
+	doc, err := ParseString(input, &options)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, map[string]interface{}{"title": "Post", "tags": []interface{}{"news"}}, doc.FrontMatter)
+	assert.Equal(t, "title: Post\ntags:\n  - news\n", string(doc.RawFrontMatter))
+
+	output, err := Render(doc, &options)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, "<h1>Heading</h1><p>Text with \\q.</p>", string(output))
+
+	heading := doc.Root.FindAll(NodeKindHeading)[0]
+	assert.Equal(t, "Heading", input[heading.StartIndex:heading.EndIndex])
+
+	// errors after the front matter are located in the full input
+	options.EnableBackslashTransforms = true
+	options.EnableBackslashEscapes = false
+
+	_, err = CompileString(input, &options)
+
+	var compileErr *CompileError
+	if assert.True(t, errors.As(err, &compileErr)) {
+		assert.Equal(t, strings.Index(input, `\q`), compileErr.Offset)
+		assert.Equal(t, 8, compileErr.Line)
+		assert.Equal(t, 11, compileErr.Column)
+	}
+}
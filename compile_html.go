@@ -0,0 +1,331 @@
+package slimdown
+
+import (
+	"html"
+	"strings"
+)
+
+var (
+	compileHTMLDefaultAllowedTags = map[string][]string{
+		"a":       {"href", "title"},
+		"abbr":    {"title"},
+		"b":       nil,
+		"br":      nil,
+		"cite":    nil,
+		"code":    nil,
+		"dd":      nil,
+		"del":     nil,
+		"details": {"open"},
+		"dfn":     {"title"},
+		"dl":      nil,
+		"dt":      nil,
+		"em":      nil,
+		"i":       nil,
+		"img":     {"alt", "height", "src", "title", "width"},
+		"ins":     nil,
+		"kbd":     nil,
+		"mark":    nil,
+		"q":       {"cite"},
+		"s":       nil,
+		"samp":    nil,
+		"small":   nil,
+		"span":    nil,
+		"strong":  nil,
+		"sub":     nil,
+		"summary": nil,
+		"sup":     nil,
+		"u":       nil,
+		"var":     nil,
+	}
+	compileHTMLVoidTags = map[string]bool{
+		"area":   true,
+		"base":   true,
+		"br":     true,
+		"col":    true,
+		"embed":  true,
+		"hr":     true,
+		"img":    true,
+		"input":  true,
+		"link":   true,
+		"meta":   true,
+		"source": true,
+		"track":  true,
+		"wbr":    true,
+	}
+	compileHTMLURLAttributes = map[string]bool{
+		"action":     true,
+		"background": true,
+		"cite":       true,
+		"formaction": true,
+		"href":       true,
+		"poster":     true,
+		"src":        true,
+		"xlink:href": true,
+	}
+)
+
+type compileHTMLAttribute struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+type compileHTMLTag struct {
+	name       string
+	attributes []compileHTMLAttribute
+	isClosing  bool
+	isComment  bool
+	endIndex   int
+}
+
+// Tags must fit on a single line, so that they never hide the line breaks
+// that the block passes depend upon.
+func compileHTMLFindTag(input []byte, i int) (tag compileHTMLTag, ok bool) {
+	l := len(input)
+
+	if i >= l || input[i] != '<' {
+		return
+	}
+	i++
+
+	if i+2 < l && input[i] == '!' && input[i+1] == '-' && input[i+2] == '-' {
+		for j := i + 3; j+2 < l && input[j] != '\n'; j++ {
+			if input[j] == '-' && input[j+1] == '-' && input[j+2] == '>' {
+				tag.isComment = true
+				tag.endIndex = j + 3
+				ok = true
+				return
+			}
+		}
+
+		return
+	}
+
+	if i < l && input[i] == '/' {
+		tag.isClosing = true
+		i++
+	}
+
+	nameStartIndex := i
+	for i < l && (compileHTMLIsLetter(input[i]) || (i > nameStartIndex && (compileHTMLIsDigit(input[i]) || input[i] == '-'))) {
+		i++
+	}
+	if i == nameStartIndex {
+		return
+	}
+	tag.name = strings.ToLower(string(input[nameStartIndex:i]))
+
+	for {
+		spaceStartIndex := i
+		for i < l && (input[i] == ' ' || input[i] == '\t') {
+			i++
+		}
+
+		if i >= l {
+			return
+		}
+
+		switch input[i] {
+		case '>':
+			tag.endIndex = i + 1
+			ok = true
+			return
+		case '/':
+			if !tag.isClosing && i+1 < l && input[i+1] == '>' {
+				tag.endIndex = i + 2
+				ok = true
+			}
+			return
+		}
+
+		if tag.isClosing || i == spaceStartIndex {
+			return
+		}
+
+		var attribute compileHTMLAttribute
+
+		attributeStartIndex := i
+		for i < l && (compileHTMLIsLetter(input[i]) || input[i] == '_' || input[i] == ':' ||
+			(i > attributeStartIndex && (compileHTMLIsDigit(input[i]) || input[i] == '.' || input[i] == '-'))) {
+			i++
+		}
+		if i == attributeStartIndex {
+			return
+		}
+		attribute.name = strings.ToLower(string(input[attributeStartIndex:i]))
+
+		j := i
+		for j < l && (input[j] == ' ' || input[j] == '\t') {
+			j++
+		}
+
+		if j < l && input[j] == '=' {
+			j++
+			for j < l && (input[j] == ' ' || input[j] == '\t') {
+				j++
+			}
+
+			if j >= l {
+				return
+			}
+
+			valueStartIndex := j
+
+			switch quote := input[j]; quote {
+			case '"', '\'':
+				j++
+				for j < l && input[j] != quote {
+					if input[j] == '\n' {
+						return
+					}
+					j++
+				}
+				if j >= l {
+					return
+				}
+
+				attribute.value = string(input[valueStartIndex+1 : j])
+				j++
+			default:
+				for j < l && !strings.ContainsRune(" \t\r\n\"'=<>`", rune(input[j])) {
+					j++
+				}
+				if j == valueStartIndex {
+					return
+				}
+
+				attribute.value = string(input[valueStartIndex:j])
+			}
+
+			attribute.hasValue = true
+			i = j
+		}
+
+		tag.attributes = append(tag.attributes, attribute)
+	}
+}
+
+func compileHTMLIsLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func compileHTMLIsDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// Only allowed tags and attributes are kept, and the tag is rebuilt rather
+// than copied, so that nothing from the input reaches the output unescaped.
+func compileHTMLSanitizeTag(tag compileHTMLTag, options *Options) (output string, ok bool) {
+	if tag.isComment {
+		ok = true
+		return
+	}
+
+	allowedTags := options.AllowedHTMLTags
+	if allowedTags == nil {
+		allowedTags = compileHTMLDefaultAllowedTags
+	}
+
+	var allowedAttributes []string
+
+	for name, attributes := range allowedTags {
+		if strings.EqualFold(name, tag.name) {
+			allowedAttributes = attributes
+			ok = true
+			break
+		}
+	}
+
+	if !ok {
+		return
+	}
+
+	var builder strings.Builder
+
+	builder.WriteByte('<')
+
+	if tag.isClosing {
+		builder.WriteByte('/')
+	}
+
+	builder.WriteString(tag.name)
+
+	for _, attribute := range tag.attributes {
+		if !compileHTMLIsAttributeAllowed(attribute.name, allowedAttributes) {
+			continue
+		}
+
+		value := html.UnescapeString(attribute.value)
+
+		if compileHTMLURLAttributes[attribute.name] && !compileURLIsAllowed(value, options) {
+			continue
+		}
+
+		builder.WriteByte(' ')
+		builder.WriteString(attribute.name)
+
+		if attribute.hasValue {
+			builder.WriteByte('=')
+			builder.WriteByte('"')
+			htmlRendererAttributeEscaper.WriteString(&builder, value)
+			builder.WriteByte('"')
+		}
+	}
+
+	builder.WriteByte('>')
+
+	output = builder.String()
+
+	return
+}
+
+func compileHTMLIsAttributeAllowed(name string, allowedAttributes []string) bool {
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+
+	for _, a := range allowedAttributes {
+		if strings.EqualFold(a, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The open tags are kept on a stack, so that a closing tag also closes any
+// tag left open inside it, and one that closes nothing is dropped.
+func compileHTMLBalanceTag(tag compileHTMLTag, output string, openTags *[]string) (balanced string, ok bool) {
+	if tag.isComment || compileHTMLVoidTags[tag.name] {
+		balanced, ok = output, true
+		return
+	}
+
+	if !tag.isClosing {
+		*openTags = append(*openTags, tag.name)
+		balanced, ok = output, true
+		return
+	}
+
+	for i := len(*openTags) - 1; i >= 0; i-- {
+		if (*openTags)[i] == tag.name {
+			balanced, ok = compileHTMLCloseTags((*openTags)[i+1:])+output, true
+			*openTags = (*openTags)[:i]
+			return
+		}
+	}
+
+	return
+}
+
+func compileHTMLCloseTags(openTags []string) string {
+	var builder strings.Builder
+
+	for i := len(openTags) - 1; i >= 0; i-- {
+		builder.WriteString("</")
+		builder.WriteString(openTags[i])
+		builder.WriteByte('>')
+	}
+
+	return builder.String()
+}
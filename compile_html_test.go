@@ -0,0 +1,61 @@
+package slimdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileHTMLFindTag(t *testing.T) {
+	for input, expected := range map[string]bool{
+		`<kbd>`:                        true,
+		`</kbd >`:                      true,
+		`<br/>`:                        true,
+		`<br />`:                       true,
+		`<img src=x alt='a b' data-x>`: true,
+		`<!-- comment -->`:             true,
+		`<https://example.com>`:        false,
+		`<me@example.com>`:             false,
+		`< kbd>`:                       false,
+		`<kbd`:                         false,
+		`</kbd x>`:                     false,
+		`<a href="x"title="y">`:        false,
+		"<a href=\"x\ny\">":            false,
+		"<!-- a\nb -->":                false,
+	} {
+		_, ok := compileHTMLFindTag([]byte(input), 0)
+		assert.Equal(t, expected, ok, input)
+	}
+}
+
+func TestCompileString_sanitizeHTMLAllowedTags(t *testing.T) {
+	options := DefaultOptions
+	options.AllowHTML = true
+	options.SanitizeHTML = true
+	options.AllowedHTMLTags = map[string][]string{
+		"SPAN": {"Class"},
+	}
+
+	output, err := CompileString(`<span class="note" style="color: red">Note</span> <kbd>K</kbd>`, &options)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(
+		t,
+		`<p><span class="note">Note</span> &lt;kbd&gt;K&lt;/kbd&gt;</p>`,
+		string(output),
+	)
+}
+
+func TestCompileString_allowHTMLWithoutSanitizing(t *testing.T) {
+	options := DefaultOptions
+	options.AllowHTML = true
+
+	output, err := CompileString(`<span style="color: red">Red</span> <!-- kept -->`, &options)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, `<p><span style="color: red">Red</span> <!-- kept --></p>`, string(output))
+}
@@ -0,0 +1,253 @@
+package slimdown
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+type compileReaderFlusher interface {
+	Flush()
+}
+
+func CompileReaderDefault(r io.Reader, w io.Writer) (err error) {
+	return CompileReader(r, w, nil)
+}
+
+// CompileReader writes each block to w as soon as the blank line closing it has been read.
+// Link references can therefore only be resolved against definitions that precede them,
+// a footnote reference is numbered before its definition may have been read, so it is linked even if it is never defined,
+// and a table of contents placeholder only lists the headings in its own block.
+// Front matter is removed, but its values cannot be returned.
+func CompileReader(r io.Reader, w io.Writer, options *Options) (err error) {
+	if options == nil || options == &DefaultOptions {
+		options = DefaultOptions.clone()
+	}
+
+	renderer := options.Renderer
+	if renderer == nil {
+		renderer = HTMLRendererNew(options)
+	}
+
+	root := DocumentNew(nil).Root
+	context := parseContextNew()
+	context.footnotes.isDeferred = true
+
+	if _, err = renderer.RenderDocument(w, root, true); err != nil {
+		return
+	}
+
+	reader := bufio.NewReader(r)
+
+	// the offset and line of the chunk are kept, so that errors can be given in terms of the full input
+	var offset, lines, chunkOffset, chunkLines int
+
+	if options.EnableFrontMatter {
+		var frontMatter []byte
+		if reader, frontMatter, err = compileReaderSkipFrontMatter(reader); err != nil {
+			return
+		}
+
+		offset, lines = len(frontMatter), bytes.Count(frontMatter, []byte{'\n'})
+
+		// only the start of the input may hold front matter, not the start of every block
+		chunkOptions := *options
+		chunkOptions.EnableFrontMatter = false
+		options = &chunkOptions
+	}
+
+	var chunk, blankLines bytes.Buffer
+	var fence compileReaderFence
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			err = readErr
+			return
+		}
+
+		if len(line) > 0 {
+			if !fence.isOpen() && compileReaderIsBlankLine(line) {
+				blankLines.Write(line)
+			} else {
+				if blankLines.Len() > 0 {
+					if fence.isOpen() || compileReaderContinuesBlock(line) {
+						chunk.Write(blankLines.Bytes())
+					} else if err = compileReaderWriteChunk(w, renderer, bytes.TrimRight(chunk.Bytes(), "\r\n"), chunkOffset, chunkLines, context, options); err != nil {
+						return
+					} else {
+						chunk.Reset()
+					}
+
+					blankLines.Reset()
+				}
+
+				if options.EnableFencedCodeBlocks {
+					fence.update(line)
+				}
+
+				if chunk.Len() == 0 {
+					chunkOffset, chunkLines = offset, lines
+				}
+
+				chunk.Write(line)
+			}
+
+			offset += len(line)
+			if line[len(line)-1] == '\n' {
+				lines++
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	// the newlines of the last block are kept, unless blank lines closed it
+	lastChunk := chunk.Bytes()
+	if blankLines.Len() > 0 {
+		lastChunk = bytes.TrimRight(lastChunk, "\r\n")
+	}
+
+	if err = compileReaderWriteChunk(w, renderer, lastChunk, chunkOffset, chunkLines, context, options); err != nil {
+		return
+	}
+
+	if section := context.footnotes.section(); section != nil {
+		if err = renderNode(w, renderer, section); err != nil {
+			return
+		}
+	}
+
+	_, err = renderer.RenderDocument(w, root, false)
+
+	return
+}
+
+// The front matter is read in full before it is parsed, since it may contain
+// blank lines; if it turns out not to be front matter, it is read again as input.
+func compileReaderSkipFrontMatter(reader *bufio.Reader) (r *bufio.Reader, frontMatter []byte, err error) {
+	var buffer bytes.Buffer
+
+	for lineCount := 1; ; lineCount++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			err = readErr
+			return
+		}
+
+		buffer.Write(line)
+
+		if lineCount == 2 && len(bytes.TrimSpace(line)) == 0 {
+			break
+		} else if lineCount > 1 {
+			text := string(bytes.TrimRight(line, " \t\r\n"))
+
+			if text == compileFrontMatterYAMLDelimiter || text == compileFrontMatterTOMLDelimiter || text == compileFrontMatterYAMLEnd {
+				if _, _, bodyIndex := compileFrontMatter(buffer.Bytes()); bodyIndex > 0 {
+					frontMatter = buffer.Next(bodyIndex)
+					break
+				}
+			}
+		} else if _, ok := compileFrontMatterDelimiter(line); !ok {
+			break
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	r = bufio.NewReader(io.MultiReader(&buffer, reader))
+
+	return
+}
+
+func compileReaderWriteChunk(
+	w io.Writer,
+	renderer Renderer,
+	chunk []byte,
+	offset, lines int,
+	context *parseContext,
+	options *Options,
+) (err error) {
+	if len(chunk) == 0 {
+		return
+	}
+
+	doc, err := parseDocument(chunk, options, context)
+	if err != nil {
+		if e, ok := err.(*CompileError); ok {
+			e.shift(offset, lines)
+		}
+
+		return
+	}
+
+	for _, n := range doc.Root.Children {
+		if err = renderNode(w, renderer, n); err != nil {
+			return
+		}
+	}
+
+	if f, ok := w.(compileReaderFlusher); ok {
+		f.Flush()
+	}
+
+	return
+}
+
+// A line of only spaces is not blank, since Compile keeps it as text.
+func compileReaderIsBlankLine(line []byte) bool {
+	return len(bytes.TrimRight(line, "\r\n")) == 0
+}
+
+func compileReaderContinuesBlock(line []byte) bool {
+	switch line[0] {
+	case ' ', '\t', '>', '*', '-', '+':
+		return true
+	}
+
+	var digitsLen int
+	for digitsLen < len(line) && line[digitsLen] >= '0' && line[digitsLen] <= '9' {
+		digitsLen++
+	}
+
+	if digitsLen > 0 && digitsLen < len(line) {
+		switch line[digitsLen] {
+		case '.', ')':
+			return true
+		}
+	}
+
+	return false
+}
+
+type compileReaderFence struct {
+	fenceByte byte
+	fenceLen  int
+}
+
+func (f *compileReaderFence) isOpen() bool {
+	return f.fenceLen > 0
+}
+
+func (f *compileReaderFence) update(line []byte) {
+	if f.isOpen() {
+		if compileTokenizeIsClosingFence(bytes.TrimRight(line, "\n"), f.fenceByte, f.fenceLen) {
+			f.fenceLen = 0
+		}
+
+		return
+	}
+
+	if block, ok := compileTokenizeFindFencedCodeBlock(line, 0); ok && block.contentStartIndex >= len(line) {
+		trimmed := bytes.TrimLeft(line, " ")
+		f.fenceByte = trimmed[0]
+
+		for f.fenceLen < len(trimmed) && trimmed[f.fenceLen] == f.fenceByte {
+			f.fenceLen++
+		}
+	}
+}
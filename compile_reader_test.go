@@ -0,0 +1,184 @@
+package slimdown
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileReader_matchesCompile(t *testing.T) {
+	for _, key := range []string{
+		"blockquotes",
+		"orderedLists",
+		"fencedCodeBlocks",
+		"tables",
+	} {
+		var buff bytes.Buffer
+
+		err := CompileReader(bytes.NewReader(testCompileStringInput[key]), &buff, testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+
+		assert.Equal(t, string(testCompileStringExpectedOutput[key]), buff.String(), key)
+	}
+}
+
+func TestCompileReader_whitespaceMatchesCompile(t *testing.T) {
+	for _, input := range []string{
+		"   ",
+		"\t\n",
+		"a\n\n   \n\nb",
+		"a\n \nb",
+		"a\n\n",
+	} {
+		options := DefaultOptions
+		options.CleanEmptyTags = true
+
+		expected, err := CompileString(input, &options)
+		if err != nil {
+			panic(err)
+		}
+
+		var builder strings.Builder
+
+		if err = CompileReader(strings.NewReader(input), &builder, &options); err != nil {
+			panic(err)
+		}
+
+		assert.Equal(t, string(expected), builder.String(), input)
+	}
+}
+
+func TestCompileReader_documentTags(t *testing.T) {
+	const input = "# Title\n\n```\na\n\nb\n```\n\n* one\n\n* two\n\nEnd."
+
+	options := DefaultOptions
+	options.EnableDocumentTags = true
+	options.EnableFencedCodeBlocks = true
+
+	var builder strings.Builder
+
+	if err := CompileReader(strings.NewReader(input), &builder, &options); err != nil {
+		panic(err)
+	}
+
+	assert.Equal(
+		t,
+		"<!DOCTYPE html><html><head></head><body><h1>Title</h1><pre><code>a\n\nb\n</code></pre><ul><li>one</li><li>two</li></ul><p>End.</p></body></html>",
+		builder.String(),
+	)
+}
+
+func TestCompileReader_referenceLinks(t *testing.T) {
+	const input = "[a]: /a\n\nSee [a] and [b].\n\n```\n[c]: /c\n```\n\n[b]: /b\n\nThen [b], [c] and ![a]."
+
+	options := DefaultOptions
+	options.EnableFencedCodeBlocks = true
+
+	var builder strings.Builder
+
+	if err := CompileReader(strings.NewReader(input), &builder, &options); err != nil {
+		panic(err)
+	}
+
+	assert.Equal(
+		t,
+		"<p>See <a href=\"/a\">a</a> and [b].</p><pre><code>[c]: /c\n</code></pre><p>Then <a href=\"/b\">b</a>, [c] and <img alt=\"a\" src=\"/a\">.</p>",
+		builder.String(),
+	)
+}
+
+func TestCompileReader_headingIDs(t *testing.T) {
+	const input = "# Notes\n\nText.\n\n# Notes"
+
+	options := DefaultOptions
+	options.EnableHeadingIDs = true
+
+	var builder strings.Builder
+
+	if err := CompileReader(strings.NewReader(input), &builder, &options); err != nil {
+		panic(err)
+	}
+
+	assert.Equal(
+		t,
+		"<h1 id=\"notes\">Notes</h1><p>Text.</p><h1 id=\"notes-1\">Notes</h1>",
+		builder.String(),
+	)
+}
+
+func TestCompileReader_frontMatter(t *testing.T) {
+	const input = "---\ntitle: Post\n\ntags: [a]\nauthor:\n  name: Jo\n---\n# Heading\n\n---\n\nEnd."
+
+	options := DefaultOptions
+	options.EnableFrontMatter = true
+
+	var builder strings.Builder
+
+	if err := CompileReader(strings.NewReader(input), &builder, &options); err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, "<h1>Heading</h1><hr><p>End.</p>", builder.String())
+}
+
+func TestCompileReader_errorPosition(t *testing.T) {
+	const input = "---\ntitle: Post\n---\n[a]: /a\n\nb\n\nc \\q"
+
+	options := DefaultOptions
+	options.EnableBackslashTransforms = true
+	options.EnableFrontMatter = true
+
+	_, compileErr := CompileString(input, &options)
+	readerErr := CompileReader(strings.NewReader(input), io.Discard, &options)
+
+	var e *CompileError
+	if assert.True(t, errors.As(readerErr, &e)) {
+		assert.Equal(t, 34, e.Offset)
+		assert.Equal(t, 8, e.Line)
+		assert.Equal(t, 3, e.Column)
+	}
+
+	assert.Equal(t, compileErr, readerErr)
+}
+
+func TestCompileReader_footnotes(t *testing.T) {
+	const input = "[^1]: one\n\nText[^1].\n\nMore[^1] and[^2].\n\n[^2]: two[^3]\n\n[^3]: three"
+
+	options := DefaultOptions
+	options.EnableFootnotes = true
+
+	var builder strings.Builder
+
+	if err := CompileReader(strings.NewReader(input), &builder, &options); err != nil {
+		panic(err)
+	}
+
+	output, err := CompileString(input, &options)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(output), builder.String())
+	assert.Equal(t, 1, strings.Count(builder.String(), `id="fnref-1"`))
+}
+
+func TestCompileReader_notFrontMatter(t *testing.T) {
+	const input = "---\n\nIntro paragraph.\n\n---\n\nMore."
+
+	options := DefaultOptions
+	options.EnableFrontMatter = true
+
+	var builder strings.Builder
+
+	if err := CompileReader(strings.NewReader(input), &builder, &options); err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, "<hr><p>Intro paragraph.</p><hr><p>More.</p>", builder.String())
+}
@@ -20,6 +20,30 @@ func init() {
 		"blockquotes",
 		"spacesToTab",
 		"tabToSpaces",
+		"orderedLists",
+		"fencedCodeBlocks",
+		"tables",
+		"backslashEscapes",
+		"backslashTransforms",
+		"unsafeURLs",
+		"unsafeURLsAsText",
+		"attributeEscaping",
+		"sanitizeHTML",
+		"sanitizeHTMLUnbalanced",
+		"referenceLinks",
+		"footnotes",
+		"headingIDs",
+		"tableOfContents",
+		"frontMatter",
+		"delimiters",
+		"taskLists",
+		"nestedLists",
+		"hyphenLists",
+		"setextHeadings",
+		"indentedCodeBlocks",
+		"softLineBreaks",
+		"typography",
+		"autolinks",
 	} {
 		prefix := filePathPrefix + key
 		input := assets.Load(prefix + "Input.md")
@@ -126,3 +150,873 @@ func BenchmarkCompileString_tabToSpaces(b *testing.B) {
 		}
 	}
 }
+
+/* orderedLists */
+
+func init() {
+	testCompileStringOptions["orderedLists"] = &Options{
+		EnableEmTags:       true,
+		EnableHeadings:     true,
+		EnableLists:        true,
+		EnableOrderedLists: true,
+		EnableParagraphs:   true,
+	}
+}
+
+func TestCompileString_orderedLists(t *testing.T) {
+	const key = "orderedLists"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_orderedLists(b *testing.B) {
+	const key = "orderedLists"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* fencedCodeBlocks */
+
+func init() {
+	testCompileStringOptions["fencedCodeBlocks"] = &Options{
+		EnableEmTags:           true,
+		EnableFencedCodeBlocks: true,
+		EnableHyphenTransforms: true,
+		EnableLinks:            true,
+		EnableParagraphs:       true,
+		TabToSpaces:            2,
+	}
+}
+
+func TestCompileString_fencedCodeBlocks(t *testing.T) {
+	const key = "fencedCodeBlocks"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_fencedCodeBlocks(b *testing.B) {
+	const key = "fencedCodeBlocks"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* tables */
+
+func init() {
+	testCompileStringOptions["tables"] = &Options{
+		EnableCodeTags:         true,
+		EnableEmTags:           true,
+		EnableHyphenTransforms: true,
+		EnableLinks:            true,
+		EnableParagraphs:       true,
+		EnableStrongTags:       true,
+		EnableTables:           true,
+	}
+}
+
+func TestCompileString_tables(t *testing.T) {
+	const key = "tables"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_tables(b *testing.B) {
+	const key = "tables"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* backslashEscapes */
+
+func init() {
+	testCompileStringOptions["backslashEscapes"] = &Options{
+		EnableBackslashEscapes: true,
+		EnableCodeTags:         true,
+		EnableEmTags:           true,
+		EnableHeadings:         true,
+		EnableLists:            true,
+		EnableOrderedLists:     true,
+		EnableParagraphs:       true,
+	}
+}
+
+func TestCompileString_backslashEscapes(t *testing.T) {
+	const key = "backslashEscapes"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_backslashEscapes(b *testing.B) {
+	const key = "backslashEscapes"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* backslashTransforms */
+
+func init() {
+	testCompileStringOptions["backslashTransforms"] = &Options{
+		EnableBackslashEscapes:    true,
+		EnableBackslashTransforms: true,
+		EnableEmTags:              true,
+		EnableParagraphs:          true,
+	}
+}
+
+func TestCompileString_backslashTransforms(t *testing.T) {
+	const key = "backslashTransforms"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_backslashTransforms(b *testing.B) {
+	const key = "backslashTransforms"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* unsafeURLs */
+
+func init() {
+	testCompileStringOptions["unsafeURLs"] = &Options{
+		EnableImages:     true,
+		EnableLinks:      true,
+		EnableParagraphs: true,
+	}
+}
+
+func TestCompileString_unsafeURLs(t *testing.T) {
+	const key = "unsafeURLs"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_unsafeURLs(b *testing.B) {
+	const key = "unsafeURLs"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* unsafeURLsAsText */
+
+func init() {
+	testCompileStringOptions["unsafeURLsAsText"] = &Options{
+		AllowedURLSchemes:      []string{"https", "ftp"},
+		DisallowRelativeURLs:   true,
+		EnableLinks:            true,
+		EnableParagraphs:       true,
+		RenderUnsafeURLsAsText: true,
+	}
+}
+
+func TestCompileString_unsafeURLsAsText(t *testing.T) {
+	const key = "unsafeURLsAsText"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_unsafeURLsAsText(b *testing.B) {
+	const key = "unsafeURLsAsText"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* attributeEscaping */
+
+func init() {
+	testCompileStringOptions["attributeEscaping"] = &Options{
+		EnableBackslashEscapes: true,
+		EnableFencedCodeBlocks: true,
+		EnableImages:           true,
+		EnableLinks:            true,
+		EnableParagraphs:       true,
+	}
+}
+
+func TestCompileString_attributeEscaping(t *testing.T) {
+	const key = "attributeEscaping"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_attributeEscaping(b *testing.B) {
+	const key = "attributeEscaping"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* sanitizeHTML */
+
+func init() {
+	testCompileStringOptions["sanitizeHTML"] = &Options{
+		AllowHTML:        true,
+		EnableCodeTags:   true,
+		EnableEmTags:     true,
+		EnableLinks:      true,
+		EnableParagraphs: true,
+		SanitizeHTML:     true,
+	}
+}
+
+func TestCompileString_sanitizeHTML(t *testing.T) {
+	const key = "sanitizeHTML"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_sanitizeHTML(b *testing.B) {
+	const key = "sanitizeHTML"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* sanitizeHTMLUnbalanced */
+
+func init() {
+	testCompileStringOptions["sanitizeHTMLUnbalanced"] = &Options{
+		AllowHTML:        true,
+		EnableCodeTags:   true,
+		EnableEmTags:     true,
+		EnableLinks:      true,
+		EnableParagraphs: true,
+		SanitizeHTML:     true,
+	}
+}
+
+func TestCompileString_sanitizeHTMLUnbalanced(t *testing.T) {
+	const key = "sanitizeHTMLUnbalanced"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_sanitizeHTMLUnbalanced(b *testing.B) {
+	const key = "sanitizeHTMLUnbalanced"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* referenceLinks */
+
+func init() {
+	testCompileStringOptions["referenceLinks"] = &Options{
+		EnableHeadings:   true,
+		EnableImages:     true,
+		EnableLinks:      true,
+		EnableParagraphs: true,
+	}
+}
+
+func TestCompileString_referenceLinks(t *testing.T) {
+	const key = "referenceLinks"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_referenceLinks(b *testing.B) {
+	const key = "referenceLinks"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* footnotes */
+
+func init() {
+	testCompileStringOptions["footnotes"] = &Options{
+		EnableCodeTags:     true,
+		EnableDocumentTags: true,
+		EnableEmTags:       true,
+		EnableFootnotes:    true,
+		EnableHeadings:     true,
+		EnableLinks:        true,
+		EnableParagraphs:   true,
+	}
+}
+
+func TestCompileString_footnotes(t *testing.T) {
+	const key = "footnotes"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_footnotes(b *testing.B) {
+	const key = "footnotes"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* headingIDs */
+
+func init() {
+	testCompileStringOptions["headingIDs"] = &Options{
+		EnableCodeTags:       true,
+		EnableDocumentTags:   true,
+		EnableEmTags:         true,
+		EnableHeadingAnchors: true,
+		EnableHeadingIDs:     true,
+		EnableHeadings:       true,
+		EnableParagraphs:     true,
+	}
+}
+
+func TestCompileString_headingIDs(t *testing.T) {
+	const key = "headingIDs"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_headingIDs(b *testing.B) {
+	const key = "headingIDs"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* tableOfContents */
+
+func init() {
+	testCompileStringOptions["tableOfContents"] = &Options{
+		EnableDocumentTags:    true,
+		EnableEmTags:          true,
+		EnableHeadings:        true,
+		EnableParagraphs:      true,
+		EnableTableOfContents: true,
+	}
+}
+
+func TestCompileString_tableOfContents(t *testing.T) {
+	const key = "tableOfContents"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_tableOfContents(b *testing.B) {
+	const key = "tableOfContents"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestCompile_lineBreakModes(t *testing.T) {
+	const input = "one\ntwo  \nthree\n"
+
+	for mode, expected := range map[LineBreakMode]string{
+		LineBreakModeAlways:  "<p>one<br>two  <br>three<br></p>",
+		LineBreakModeNewline: "<p>one\ntwo<br>three</p>",
+		LineBreakModeSpace:   "<p>one two<br>three</p>",
+	} {
+		options := DefaultOptions
+		options.LineBreakMode = mode
+
+		output, err := CompileString(input, &options)
+		if err != nil {
+			panic(err)
+		}
+
+		assert.Equal(t, expected, string(output))
+	}
+}
+
+func TestCompile_autolinksAllowedURLSchemes(t *testing.T) {
+	options := DefaultOptions
+	options.EnableAutolinks = true
+	options.AllowedURLSchemes = []string{"https"}
+
+	output, err := CompileString("https://example.com, www.example.com and someone@example.com", &options)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(
+		t,
+		`<p><a href="https://example.com">https://example.com</a>, www.example.com and someone@example.com</p>`,
+		string(output),
+	)
+}
+
+/* frontMatter */
+
+func init() {
+	testCompileStringOptions["frontMatter"] = &Options{
+		EnableDocumentTags:    true,
+		EnableEmTags:          true,
+		EnableFrontMatter:     true,
+		EnableHeadings:        true,
+		EnableHorizontalRules: true,
+		EnableParagraphs:      true,
+	}
+}
+
+func TestCompileString_frontMatter(t *testing.T) {
+	const key = "frontMatter"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_frontMatter(b *testing.B) {
+	const key = "frontMatter"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* delimiters */
+
+func init() {
+	testCompileStringOptions["delimiters"] = &Options{
+		EnableCodeTags:   true,
+		EnableDelTags:    true,
+		EnableEmTags:     true,
+		EnableInsTags:    true,
+		EnableParagraphs: true,
+		EnableSubTags:    true,
+		EnableSupTags:    true,
+	}
+}
+
+func TestCompileString_delimiters(t *testing.T) {
+	const key = "delimiters"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_delimiters(b *testing.B) {
+	const key = "delimiters"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* taskLists */
+
+func init() {
+	testCompileStringOptions["taskLists"] = &Options{
+		EnableEmTags:       true,
+		EnableHeadings:     true,
+		EnableLinks:        true,
+		EnableLists:        true,
+		EnableOrderedLists: true,
+		EnableParagraphs:   true,
+		EnableTaskLists:    true,
+	}
+}
+
+func TestCompileString_taskLists(t *testing.T) {
+	const key = "taskLists"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_taskLists(b *testing.B) {
+	const key = "taskLists"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* nestedLists */
+
+func init() {
+	testCompileStringOptions["nestedLists"] = &Options{
+		EnableEmTags:           true,
+		EnableFencedCodeBlocks: true,
+		EnableHeadings:         true,
+		EnableLists:            true,
+		EnableOrderedLists:     true,
+		EnableParagraphs:       true,
+	}
+}
+
+func TestCompileString_nestedLists(t *testing.T) {
+	const key = "nestedLists"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_nestedLists(b *testing.B) {
+	const key = "nestedLists"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* hyphenLists */
+
+func init() {
+	testCompileStringOptions["hyphenLists"] = &Options{
+		EnableHyphenTransforms: true,
+		EnableLists:            true,
+		EnableParagraphs:       true,
+	}
+}
+
+func TestCompileString_hyphenLists(t *testing.T) {
+	const key = "hyphenLists"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_hyphenLists(b *testing.B) {
+	const key = "hyphenLists"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* setextHeadings */
+
+func init() {
+	testCompileStringOptions["setextHeadings"] = &Options{
+		EnableHeadings:         true,
+		EnableHorizontalRules:  true,
+		EnableHyphenTransforms: true,
+		EnableLists:            true,
+		EnableParagraphs:       true,
+	}
+}
+
+func TestCompileString_setextHeadings(t *testing.T) {
+	const key = "setextHeadings"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_setextHeadings(b *testing.B) {
+	const key = "setextHeadings"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* indentedCodeBlocks */
+
+func init() {
+	testCompileStringOptions["indentedCodeBlocks"] = &Options{
+		EnableIndentedCodeBlocks: true,
+		EnableLists:              true,
+		EnableParagraphs:         true,
+		SpacesToTab:              4,
+	}
+}
+
+func TestCompileString_indentedCodeBlocks(t *testing.T) {
+	const key = "indentedCodeBlocks"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_indentedCodeBlocks(b *testing.B) {
+	const key = "indentedCodeBlocks"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* softLineBreaks */
+
+func init() {
+	testCompileStringOptions["softLineBreaks"] = &Options{
+		EnableBackslashTransforms: true,
+		EnableBlockquotes:         true,
+		EnableHeadings:            true,
+		EnableLists:               true,
+		EnableParagraphs:          true,
+		LineBreakMode:             LineBreakModeNewline,
+	}
+}
+
+func TestCompileString_softLineBreaks(t *testing.T) {
+	const key = "softLineBreaks"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_softLineBreaks(b *testing.B) {
+	const key = "softLineBreaks"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* typography */
+
+func init() {
+	testCompileStringOptions["typography"] = &Options{
+		EnableBackslashEscapes: true,
+		EnableCodeTags:         true,
+		EnableEmTags:           true,
+		EnableFencedCodeBlocks: true,
+		EnableHeadings:         true,
+		EnableParagraphs:       true,
+		EnableTypography:       true,
+		LineBreakMode:          LineBreakModeSpace,
+	}
+}
+
+func TestCompileString_typography(t *testing.T) {
+	const key = "typography"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_typography(b *testing.B) {
+	const key = "typography"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+/* autolinks */
+
+func init() {
+	testCompileStringOptions["autolinks"] = &Options{
+		EnableAutolinks:  true,
+		EnableCodeTags:   true,
+		EnableEmTags:     true,
+		EnableHeadings:   true,
+		EnableLinks:      true,
+		EnableLists:      true,
+		EnableParagraphs: true,
+		LineBreakMode:    LineBreakModeSpace,
+	}
+}
+
+func TestCompileString_autolinks(t *testing.T) {
+	const key = "autolinks"
+
+	output, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output))
+}
+
+func BenchmarkCompileString_autolinks(b *testing.B) {
+	const key = "autolinks"
+
+	for i := 0; i < b.N; i++ {
+		_, err := Compile(testCompileStringInput[key], testCompileStringOptions[key])
+		if err != nil {
+			panic(err)
+		}
+	}
+}
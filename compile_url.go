@@ -0,0 +1,65 @@
+package slimdown
+
+import (
+	"html"
+	"strings"
+)
+
+var (
+	compileURLDefaultSchemes = []string{"http", "https", "mailto"}
+)
+
+func compileURLIsAllowed(rawURL string, options *Options) bool {
+	scheme, isRelative := compileURLScheme(rawURL)
+
+	if isRelative {
+		return !options.DisallowRelativeURLs
+	}
+
+	schemes := options.AllowedURLSchemes
+	if schemes == nil {
+		schemes = compileURLDefaultSchemes
+	}
+
+	for _, s := range schemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Browsers decode entities in attribute values and ignore whitespace and
+// control characters in a URL, so these are removed before the scheme is read.
+func compileURLScheme(rawURL string) (scheme string, isRelative bool) {
+	normalized := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+
+		return r
+	}, html.UnescapeString(rawURL))
+
+	i := strings.IndexAny(normalized, ":/?#")
+	if i < 0 || normalized[i] != ':' {
+		isRelative = true
+		return
+	}
+
+	scheme = strings.ToLower(normalized[:i])
+
+	for j, r := range scheme {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case j > 0 && ((r >= '0' && r <= '9') || r == '+' || r == '-' || r == '.'):
+		default:
+			// not a valid scheme, so browsers resolve the URL as a relative path
+			scheme = ""
+			isRelative = true
+			return
+		}
+	}
+
+	return
+}
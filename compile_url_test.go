@@ -0,0 +1,56 @@
+package slimdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileURLIsAllowed_obfuscatedSchemes(t *testing.T) {
+	options := DefaultOptions.clone()
+
+	for rawURL, expected := range map[string]bool{
+		"https://example.com":           true,
+		"HTTP://example.com":            true,
+		"mailto:someone@example.com":    true,
+		"/relative/path?q=1#top":        true,
+		"//example.com/scheme-relative": true,
+		"relative:looking/../path":      false,
+		"javascript:alert(1)":           false,
+		"JaVaScRiPt:alert(1)":           false,
+		" javascript:alert(1)":          false,
+		"java\tscript:alert(1)":         false,
+		"java\nscript:alert(1)":         false,
+		"java\x00script:alert(1)":       false,
+		"javascript&colon;alert(1)":     false,
+		"java&#115;cript:alert(1)":      false,
+		"&#x6A;avascript:alert(1)":      false,
+		"&#0000106avascript:alert(1)":   false,
+		"vbscript:msgbox(1)":            false,
+		"data:text/html;base64,PHA+":    false,
+		"java%73cript:alert(1)":         true,
+	} {
+		assert.Equal(t, expected, compileURLIsAllowed(rawURL, options), rawURL)
+	}
+}
+
+func TestCompileURLIsAllowed_options(t *testing.T) {
+	options := DefaultOptions.clone()
+	options.AllowedURLSchemes = []string{"HTTPS", "ftp"}
+	options.DisallowRelativeURLs = true
+
+	assert.True(t, compileURLIsAllowed("https://example.com", options))
+	assert.True(t, compileURLIsAllowed("ftp://example.com", options))
+	assert.False(t, compileURLIsAllowed("http://example.com", options))
+	assert.False(t, compileURLIsAllowed("/relative", options))
+
+	options.AllowedURLSchemes = []string{}
+
+	assert.False(t, compileURLIsAllowed("https://example.com", options))
+
+	options = &Options{}
+
+	assert.True(t, compileURLIsAllowed("https://example.com", options))
+	assert.True(t, compileURLIsAllowed("/relative", options))
+	assert.False(t, compileURLIsAllowed("javascript:alert(1)", options))
+}
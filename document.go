@@ -0,0 +1,412 @@
+package slimdown
+
+import "strings"
+
+type NodeKind uint8
+
+const (
+	NodeKindDocument NodeKind = iota
+	NodeKindParagraph
+	NodeKindHeading
+	NodeKindBlockquote
+	NodeKindUnorderedList
+	NodeKindOrderedList
+	NodeKindListItem
+	NodeKindCodeBlock
+	NodeKindHorizontalRule
+	NodeKindTable
+	NodeKindTableHead
+	NodeKindTableBody
+	NodeKindTableRow
+	NodeKindTableHeaderCell
+	NodeKindTableCell
+	NodeKindText
+	NodeKindHTML
+	NodeKindLineBreak
+	NodeKindEmphasis
+	NodeKindStrong
+	NodeKindMark
+	NodeKindDelete
+	NodeKindInsert
+	NodeKindSubscript
+	NodeKindSuperscript
+	NodeKindCode
+	NodeKindLink
+	NodeKindImage
+	NodeKindTaskCheckbox
+	NodeKindFootnoteReference
+	NodeKindFootnotes
+	NodeKindFootnote
+)
+
+func (k NodeKind) String() string {
+	switch k {
+	case NodeKindDocument:
+		return "Document"
+	case NodeKindParagraph:
+		return "Paragraph"
+	case NodeKindHeading:
+		return "Heading"
+	case NodeKindBlockquote:
+		return "Blockquote"
+	case NodeKindUnorderedList:
+		return "UnorderedList"
+	case NodeKindOrderedList:
+		return "OrderedList"
+	case NodeKindListItem:
+		return "ListItem"
+	case NodeKindCodeBlock:
+		return "CodeBlock"
+	case NodeKindHorizontalRule:
+		return "HorizontalRule"
+	case NodeKindTable:
+		return "Table"
+	case NodeKindTableHead:
+		return "TableHead"
+	case NodeKindTableBody:
+		return "TableBody"
+	case NodeKindTableRow:
+		return "TableRow"
+	case NodeKindTableHeaderCell:
+		return "TableHeaderCell"
+	case NodeKindTableCell:
+		return "TableCell"
+	case NodeKindText:
+		return "Text"
+	case NodeKindHTML:
+		return "HTML"
+	case NodeKindLineBreak:
+		return "LineBreak"
+	case NodeKindEmphasis:
+		return "Emphasis"
+	case NodeKindStrong:
+		return "Strong"
+	case NodeKindMark:
+		return "Mark"
+	case NodeKindDelete:
+		return "Delete"
+	case NodeKindInsert:
+		return "Insert"
+	case NodeKindSubscript:
+		return "Subscript"
+	case NodeKindSuperscript:
+		return "Superscript"
+	case NodeKindCode:
+		return "Code"
+	case NodeKindLink:
+		return "Link"
+	case NodeKindImage:
+		return "Image"
+	case NodeKindTaskCheckbox:
+		return "TaskCheckbox"
+	case NodeKindFootnoteReference:
+		return "FootnoteReference"
+	case NodeKindFootnotes:
+		return "Footnotes"
+	case NodeKindFootnote:
+		return "Footnote"
+	}
+
+	return "Unknown"
+}
+
+func (k NodeKind) IsBlock() bool {
+	switch k {
+	case NodeKindDocument,
+		NodeKindParagraph,
+		NodeKindHeading,
+		NodeKindBlockquote,
+		NodeKindUnorderedList,
+		NodeKindOrderedList,
+		NodeKindListItem,
+		NodeKindCodeBlock,
+		NodeKindHorizontalRule,
+		NodeKindTable,
+		NodeKindTableHead,
+		NodeKindTableBody,
+		NodeKindTableRow,
+		NodeKindTableHeaderCell,
+		NodeKindTableCell,
+		NodeKindFootnotes,
+		NodeKindFootnote:
+		return true
+	}
+
+	return false
+}
+
+// StartIndex and EndIndex are byte offsets into the input,
+// or -1 when a node was not derived from the input.
+type Node struct {
+	Kind       NodeKind
+	Level      int
+	Text       string
+	Attributes map[string]string
+	StartIndex int
+	EndIndex   int
+	Parent     *Node
+	Children   []*Node
+}
+
+func NodeNew(kind NodeKind) *Node {
+	return &Node{
+		Kind:       kind,
+		StartIndex: -1,
+		EndIndex:   -1,
+	}
+}
+
+func TextNodeNew(text string) *Node {
+	n := NodeNew(NodeKindText)
+	n.Text = text
+
+	return n
+}
+
+func (n *Node) Attribute(key string) (value string, ok bool) {
+	if n.Attributes != nil {
+		value, ok = n.Attributes[key]
+	}
+
+	return
+}
+
+func (n *Node) SetAttribute(key, value string) {
+	if n.Attributes == nil {
+		n.Attributes = make(map[string]string)
+	}
+
+	n.Attributes[key] = value
+}
+
+func (n *Node) AppendChild(children ...*Node) {
+	for _, c := range children {
+		if c == nil {
+			continue
+		}
+
+		c.Remove()
+		c.Parent = n
+		n.Children = append(n.Children, c)
+	}
+}
+
+func (n *Node) InsertBefore(referenceChild *Node, children ...*Node) {
+	i := n.childIndex(referenceChild)
+	if i < 0 {
+		n.AppendChild(children...)
+		return
+	}
+
+	var inserted []*Node
+
+	for _, c := range children {
+		if c == nil || c == referenceChild {
+			continue
+		}
+
+		c.Remove()
+		c.Parent = n
+		inserted = append(inserted, c)
+	}
+
+	i = n.childIndex(referenceChild)
+	n.Children = append(n.Children[:i], append(inserted, n.Children[i:]...)...)
+}
+
+func (n *Node) Remove() {
+	p := n.Parent
+	if p == nil {
+		return
+	}
+
+	if i := p.childIndex(n); i >= 0 {
+		p.Children = append(p.Children[:i], p.Children[i+1:]...)
+	}
+
+	n.Parent = nil
+}
+
+func (n *Node) ReplaceWith(nodes ...*Node) {
+	p := n.Parent
+	if p == nil {
+		return
+	}
+
+	p.InsertBefore(n, nodes...)
+	n.Remove()
+}
+
+func (n *Node) childIndex(child *Node) int {
+	if child == nil || child.Parent != n {
+		return -1
+	}
+
+	for i, c := range n.Children {
+		if c == child {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (n *Node) FirstChild() *Node {
+	if len(n.Children) == 0 {
+		return nil
+	}
+
+	return n.Children[0]
+}
+
+func (n *Node) LastChild() *Node {
+	if l := len(n.Children); l > 0 {
+		return n.Children[l-1]
+	}
+
+	return nil
+}
+
+func (n *Node) PrevSibling() *Node {
+	if p := n.Parent; p != nil {
+		if i := p.childIndex(n); i > 0 {
+			return p.Children[i-1]
+		}
+	}
+
+	return nil
+}
+
+func (n *Node) NextSibling() *Node {
+	if p := n.Parent; p != nil {
+		if i := p.childIndex(n); i >= 0 && i < len(p.Children)-1 {
+			return p.Children[i+1]
+		}
+	}
+
+	return nil
+}
+
+// Walk visits the node and its descendants in document order,
+// skipping the descendants of any node for which fn returns false.
+func (n *Node) Walk(fn func(n *Node) bool) {
+	if !fn(n) {
+		return
+	}
+
+	for _, c := range append([]*Node(nil), n.Children...) {
+		c.Walk(fn)
+	}
+}
+
+func (n *Node) FindAll(kind NodeKind) (nodes []*Node) {
+	n.Walk(func(n2 *Node) bool {
+		if n2.Kind == kind {
+			nodes = append(nodes, n2)
+		}
+
+		return true
+	})
+
+	return
+}
+
+func (n *Node) TextContent() string {
+	var builder strings.Builder
+
+	n.Walk(func(n2 *Node) bool {
+		if n2.Kind == NodeKindText {
+			builder.WriteString(n2.Text)
+		}
+
+		return true
+	})
+
+	return builder.String()
+}
+
+// FrontMatter holds the values from the front matter at the start of the
+// input, which is not part of the tree; it is nil when there is none.
+// RawFrontMatter holds the lines between its delimiters, for a full YAML or
+// TOML decoder, as told by the delimiter at the start of the input.
+// UnreferencedFootnotes lists the labels of the footnote definitions that
+// nothing references, which are left out of the tree.
+type Document struct {
+	Root                  *Node
+	Input                 []byte
+	FrontMatter           map[string]interface{}
+	RawFrontMatter        []byte
+	UnreferencedFootnotes []string
+}
+
+func DocumentNew(input []byte) *Document {
+	root := NodeNew(NodeKindDocument)
+	root.StartIndex = 0
+	root.EndIndex = len(input)
+
+	return &Document{
+		Root:  root,
+		Input: input,
+	}
+}
+
+type TableOfContentsEntry struct {
+	Level int
+	Text  string
+	ID    string
+}
+
+// TableOfContents lists the headings in document order; the ids are
+// only set when they have been generated, or added to the nodes by hand.
+func (d *Document) TableOfContents() []TableOfContentsEntry {
+	return documentTableOfContents(d.Root)
+}
+
+func documentTableOfContents(root *Node) (entries []TableOfContentsEntry) {
+	for _, n := range root.FindAll(NodeKindHeading) {
+		id, _ := n.Attribute("id")
+
+		entries = append(entries, TableOfContentsEntry{
+			Level: n.Level,
+			Text:  documentHeadingText(n),
+			ID:    id,
+		})
+	}
+
+	return
+}
+
+// Anchor links and footnote numbers are not part of the heading's own text.
+func documentHeadingText(n *Node) string {
+	var builder strings.Builder
+
+	n.Walk(func(n2 *Node) bool {
+		switch n2.Kind {
+		case NodeKindText:
+			builder.WriteString(n2.Text)
+		case NodeKindFootnoteReference:
+			return false
+		case NodeKindLink:
+			if class, _ := n2.Attribute("class"); class == parseHeadingAnchorClass {
+				return false
+			}
+		}
+
+		return true
+	})
+
+	return builder.String()
+}
+
+// TaskCounts counts the task list items in the document, and those that are checked.
+func (d *Document) TaskCounts() (completed, total int) {
+	for _, n := range d.Root.FindAll(NodeKindTaskCheckbox) {
+		if _, ok := n.Attribute("checked"); ok {
+			completed++
+		}
+
+		total++
+	}
+
+	return
+}
@@ -1,9 +1,95 @@
 package slimdown
 
-import "errors"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/theTardigrade/golang-slimdown/internal/tokenization"
+)
 
 var (
 	ErrCompileTokenStackOverflow        = errors.New("token stack overflow") // unused
 	ErrCompileTokenTypeUnknown          = errors.New("token type unknown")
 	ErrCompileBackslashTransformUnknown = errors.New("backslash transform unknown")
+	ErrRenderNodeKindUnknown            = errors.New("node kind unknown")
 )
+
+const (
+	compileErrorSnippetMaxLen = 24
+)
+
+// Line and Column are one-based, with Column counted in runes.
+type CompileError struct {
+	Err     error
+	Pass    string
+	Offset  int
+	Line    int
+	Column  int
+	Snippet string
+}
+
+func compileErrorNew(err error, pass string, t *tokenization.Token) *CompileError {
+	var input []byte
+	if t.ListCollection != nil {
+		input = t.ListCollection.Input
+	}
+
+	return compileErrorNewAtOffset(err, pass, input, t.InputStartIndex)
+}
+
+func compileErrorNewAtOffset(err error, pass string, input []byte, offset int) *CompileError {
+	e := &CompileError{
+		Err:    err,
+		Pass:   pass,
+		Offset: offset,
+		Line:   1,
+		Column: 1,
+	}
+
+	if input == nil || e.Offset < 0 || e.Offset > len(input) {
+		return e
+	}
+
+	prefix := input[:e.Offset]
+	lineStartIndex := bytes.LastIndexByte(prefix, '\n') + 1
+
+	e.Line += bytes.Count(prefix, []byte{'\n'})
+	e.Column += utf8.RuneCount(prefix[lineStartIndex:])
+
+	snippet := input[e.Offset:]
+	if i := bytes.IndexAny(snippet, "\r\n"); i >= 0 {
+		snippet = snippet[:i]
+	}
+
+	if l := len(snippet); l > compileErrorSnippetMaxLen {
+		l = compileErrorSnippetMaxLen
+		for l > 0 && !utf8.RuneStart(snippet[l]) {
+			l--
+		}
+
+		snippet = snippet[:l]
+	}
+
+	e.Snippet = string(snippet)
+
+	return e
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("%s: %s at line %d, column %d: %q", e.Pass, e.Err, e.Line, e.Column, e.Snippet)
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// The input before offset, which holds the given number of lines, was not
+// tokenized along with the rest, such as front matter that has been removed
+// or the earlier blocks read by CompileReader, so the error is moved to its
+// place in the full input.
+func (e *CompileError) shift(offset, lines int) {
+	e.Offset += offset
+	e.Line += lines
+}
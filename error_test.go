@@ -0,0 +1,31 @@
+package slimdown
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileError_backslashTransform(t *testing.T) {
+	options := DefaultOptions
+	options.EnableBackslashTransforms = true
+
+	_, err := CompileString("First line\\n is fine.\n\nThe “second” has \\q here.", &options)
+
+	assert.True(t, errors.Is(err, ErrCompileBackslashTransformUnknown))
+
+	var compileErr *CompileError
+	if assert.True(t, errors.As(err, &compileErr)) {
+		assert.Equal(t, "backslash transforms", compileErr.Pass)
+		assert.Equal(t, 44, compileErr.Offset)
+		assert.Equal(t, 3, compileErr.Line)
+		assert.Equal(t, 18, compileErr.Column)
+		assert.Equal(t, `\q here.`, compileErr.Snippet)
+		assert.Equal(
+			t,
+			`backslash transforms: backslash transform unknown at line 3, column 18: "\\q here."`,
+			compileErr.Error(),
+		)
+	}
+}
@@ -5,7 +5,6 @@ type Token struct {
 	RawNext         *Token
 	ListCollection  *TokenListCollection
 	Attributes      map[string]string
-	HTML            []byte
 	InputStartIndex int
 	InputEndIndex   int
 	Type            TokenType
@@ -20,21 +19,6 @@ func tokenNew(y TokenType, inputStartIndex int, inputEndIndex int) *Token {
 	}
 }
 
-func (t *Token) SimpleCloneForClosingTag() *Token {
-	return &Token{
-		ListCollection: t.ListCollection,
-		Type:           t.Type,
-	}
-}
-
-func (t *Token) TypeDatum() *TokenTypeDatum {
-	if d, ok := tokenTypeData[t.Type]; ok {
-		return &d
-	}
-
-	return nil
-}
-
 func (t *Token) Prev() *Token {
 	if t == nil {
 		return nil
@@ -424,10 +408,6 @@ func (t *Token) Len() int {
 		return 0
 	}
 
-	if l := len(t.HTML); l > 0 {
-		return l
-	}
-
 	e, s := t.InputEndIndex, t.InputStartIndex
 
 	if e <= s {
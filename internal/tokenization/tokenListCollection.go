@@ -1,16 +1,12 @@
 package tokenization
 
-import (
-	"html/template"
-	"strings"
-)
+import "strings"
 
 type TokenListCollection struct {
-	HeadToken        *Token
-	TailToken        *Token
-	len              int
-	TagPairCleanData [][2]*Token
-	Input            []byte
+	HeadToken *Token
+	TailToken *Token
+	len       int
+	Input     []byte
 }
 
 func TokenListCollectionNew(input []byte) *TokenListCollection {
@@ -262,17 +258,3 @@ func (c *TokenListCollection) String() string {
 
 	return builder.String()
 }
-
-func (c *TokenListCollection) HTML() template.HTML {
-	var builder strings.Builder
-
-	for t := c.HeadToken; t != nil; t = t.RawNext {
-		if t.Type != TokenTypeEmpty {
-			if h := t.HTML; len(h) > 0 {
-				builder.Write(h)
-			}
-		}
-	}
-
-	return template.HTML(builder.String())
-}
@@ -31,6 +31,7 @@ const (
 	TokenTypeCarriageReturn
 	TokenTypeHorizontalRule
 	TokenTypeBackslash
+	TokenTypeBackslashEscape
 	TokenTypeAsterisk
 	TokenTypeAsteriskDouble
 	TokenTypeAsteriskTriple
@@ -49,6 +50,10 @@ const (
 	TokenTypeDashEm
 	TokenTypeDashEn
 	TokenTypeEqualsDouble
+	TokenTypeTilde
+	TokenTypeTildeDouble
+	TokenTypeCaret
+	TokenTypePlusDouble
 	TokenTypeBacktick
 	TokenTypeBacktickDouble
 	TokenTypeExclamation
@@ -61,7 +66,22 @@ const (
 	TokenTypeLinkBound
 	TokenTypeImageBound
 	TokenTypeUnorderedListBound
+	TokenTypeOrderedListBound
 	TokenTypeListItemBound
+	TokenTypeCodeBlockBound
+	TokenTypeCodeBlockText
+	TokenTypePipe
+	TokenTypeTableBound
+	TokenTypeTableHeadBound
+	TokenTypeTableBodyBound
+	TokenTypeTableRowBound
+	TokenTypeTableHeaderCellBound
+	TokenTypeTableCellBound
+	TokenTypeHTMLTag
+	TokenTypeFootnoteReference
+	TokenTypeFootnoteDefinitionBound
+	TokenTypeTaskCheckbox
+	TokenTypeLineBreakSoft
 )
 
 func (t TokenType) String() string {
@@ -112,6 +132,8 @@ func (t TokenType) String() string {
 		return "HRL"
 	case TokenTypeBackslash:
 		return "BKS"
+	case TokenTypeBackslashEscape:
+		return "BKS_ESC"
 	case TokenTypeAsterisk:
 		return "AST"
 	case TokenTypeAsteriskDouble:
@@ -148,6 +170,14 @@ func (t TokenType) String() string {
 		return "DSH_NNN"
 	case TokenTypeEqualsDouble:
 		return "EQU_DUB"
+	case TokenTypeTilde:
+		return "TLD"
+	case TokenTypeTildeDouble:
+		return "TLD_DUB"
+	case TokenTypeCaret:
+		return "CAR"
+	case TokenTypePlusDouble:
+		return "PLS_DUB"
 	case TokenTypeBacktick:
 		return "BTK"
 	case TokenTypeBacktickDouble:
@@ -172,8 +202,38 @@ func (t TokenType) String() string {
 		return "IMG_BND"
 	case TokenTypeUnorderedListBound:
 		return "UND_LST_BND"
+	case TokenTypeOrderedListBound:
+		return "ORD_LST_BND"
 	case TokenTypeListItemBound:
 		return "LST_ITM_BND"
+	case TokenTypeCodeBlockBound:
+		return "COD_BLK_BND"
+	case TokenTypeCodeBlockText:
+		return "COD_BLK_TXT"
+	case TokenTypePipe:
+		return "PIP"
+	case TokenTypeTableBound:
+		return "TBL_BND"
+	case TokenTypeTableHeadBound:
+		return "TBL_HED_BND"
+	case TokenTypeTableBodyBound:
+		return "TBL_BDY_BND"
+	case TokenTypeTableRowBound:
+		return "TBL_ROW_BND"
+	case TokenTypeTableHeaderCellBound:
+		return "TBL_HCL_BND"
+	case TokenTypeTableCellBound:
+		return "TBL_CEL_BND"
+	case TokenTypeHTMLTag:
+		return "HTM_TAG"
+	case TokenTypeFootnoteReference:
+		return "FNT_REF"
+	case TokenTypeFootnoteDefinitionBound:
+		return "FNT_DEF_BND"
+	case TokenTypeTaskCheckbox:
+		return "TSK_CHK"
+	case TokenTypeLineBreakSoft:
+		return "LBK_SFT"
 	}
 
 	return "UNK"
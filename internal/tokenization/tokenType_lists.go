@@ -6,6 +6,7 @@ var (
 		TokenTypeSpaceGroup,
 		TokenTypeTabGroup,
 		TokenTypeBackslash,
+		TokenTypeBackslashEscape,
 		TokenTypeAsterisk,
 		TokenTypeAsteriskDouble,
 		TokenTypeAsteriskTriple,
@@ -16,25 +17,41 @@ var (
 		TokenTypeHyphenDouble,
 		TokenTypeHyphenTriple,
 		TokenTypeEqualsDouble,
+		TokenTypeTilde,
+		TokenTypeTildeDouble,
+		TokenTypeCaret,
+		TokenTypePlusDouble,
 		TokenTypeBacktick,
 		TokenTypeExclamation,
 		TokenTypeParenthesisOpen,
 		TokenTypeParenthesisClose,
+		TokenTypePipe,
 	}
 	TokenTypeListLinkSegmentLink = []TokenType{
 		TokenTypeTextGroup,
+		TokenTypeBackslashEscape,
 		TokenTypeAsterisk,
 		TokenTypeAsteriskDouble,
 		TokenTypeUnderscore,
 		TokenTypeUnderscoreDouble,
+		TokenTypeTilde,
+		TokenTypeTildeDouble,
+		TokenTypeCaret,
+		TokenTypePlusDouble,
 	}
 	TokenTypeListLinkSegmentTitle = []TokenType{
 		TokenTypeTextGroup,
+		TokenTypeBackslashEscape,
 		TokenTypeAsterisk,
 		TokenTypeAsteriskDouble,
 		TokenTypeUnderscore,
 		TokenTypeUnderscoreDouble,
+		TokenTypeTilde,
+		TokenTypeTildeDouble,
+		TokenTypeCaret,
+		TokenTypePlusDouble,
 		TokenTypeSpaceGroup,
+		TokenTypePipe,
 	}
 )
 
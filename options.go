@@ -1,29 +1,72 @@
 package slimdown
 
+type LineBreakMode uint8
+
+const (
+	LineBreakModeAlways  LineBreakMode = iota // every line break is a hard break
+	LineBreakModeNewline                      // soft breaks are kept as newlines
+	LineBreakModeSpace                        // soft breaks are rendered as spaces
+)
+
+type TypographyLocale uint8
+
+const (
+	TypographyLocaleEnglish TypographyLocale = iota // “double” and ‘single’ quotes
+	TypographyLocaleGerman                          // „double“ and ‚single‘ quotes
+	TypographyLocaleFrench                          // « double » and ‹ single › quotes, with narrow no-break spaces
+)
+
 type Options struct {
 	AllowHTML                 bool
 	CleanEmptyTags            bool
 	CleanEmptyTokens          bool
 	DebugPrintOutput          bool
 	DebugPrintTokens          bool
+	DisallowRelativeURLs      bool
+	EnableAutolinks           bool
+	EnableBackslashEscapes    bool
 	EnableBackslashTransforms bool
 	EnableBlockquotes         bool
 	EnableCodeTags            bool
+	EnableDelTags             bool
 	EnableDocumentTags        bool
 	EnableEmTags              bool
+	EnableFencedCodeBlocks    bool
+	EnableFootnotes           bool
+	EnableFrontMatter         bool
+	EnableHeadingAnchors      bool
+	EnableHeadingIDs          bool
 	EnableHeadings            bool
 	EnableHorizontalRules     bool
 	EnableHyphenTransforms    bool
 	EnableImages              bool
+	EnableIndentedCodeBlocks  bool
+	EnableInsTags             bool
 	EnableLinks               bool
 	EnableLists               bool
 	EnableMarkTags            bool
+	EnableOrderedLists        bool
 	EnableParagraphs          bool
 	EnableStrongTags          bool
+	EnableSubTags             bool
+	EnableSupTags             bool
+	EnableTableOfContents     bool
+	EnableTables              bool
+	EnableTaskLists           bool
+	EnableTypography          bool
+	OrderedTableOfContents    bool
+	RenderUnsafeURLsAsText    bool
+	SanitizeHTML              bool
+	LineBreakMode             LineBreakMode
+	TypographyLocale          TypographyLocale
 	MaxConsecutiveTabs        int
 	MaxConsecutiveSpaces      int
 	SpacesToTab               int
 	TabToSpaces               int
+	AllowedHTMLTags           map[string][]string
+	AllowedURLSchemes         []string
+	HeadingSlugFunc           func(text string) string
+	Renderer                  Renderer
 
 	isCloned bool
 }
@@ -35,23 +78,48 @@ var (
 		CleanEmptyTokens:          false,
 		DebugPrintOutput:          false,
 		DebugPrintTokens:          false,
+		DisallowRelativeURLs:      false,
+		EnableAutolinks:           false,
+		EnableBackslashEscapes:    false,
 		EnableBackslashTransforms: false,
 		EnableBlockquotes:         false,
 		EnableCodeTags:            true,
+		EnableDelTags:             false,
 		EnableDocumentTags:        false,
 		EnableEmTags:              true,
+		EnableFencedCodeBlocks:    false,
+		EnableFootnotes:           false,
+		EnableFrontMatter:         false,
+		EnableHeadingAnchors:      false,
+		EnableHeadingIDs:          false,
 		EnableHeadings:            true,
 		EnableHorizontalRules:     true,
 		EnableHyphenTransforms:    true,
 		EnableImages:              true,
+		EnableIndentedCodeBlocks:  false,
+		EnableInsTags:             false,
 		EnableLinks:               true,
 		EnableLists:               true,
+		EnableOrderedLists:        false,
 		EnableParagraphs:          true,
 		EnableStrongTags:          true,
+		EnableSubTags:             false,
+		EnableSupTags:             false,
+		EnableTableOfContents:     false,
+		EnableTables:              false,
+		EnableTaskLists:           false,
+		EnableTypography:          false,
+		OrderedTableOfContents:    false,
+		RenderUnsafeURLsAsText:    false,
+		SanitizeHTML:              false,
+		LineBreakMode:             LineBreakModeAlways,
+		TypographyLocale:          TypographyLocaleEnglish,
 		MaxConsecutiveTabs:        0,
 		MaxConsecutiveSpaces:      0,
 		SpacesToTab:               0,
 		TabToSpaces:               0,
+		AllowedHTMLTags:           nil,
+		AllowedURLSchemes:         append([]string(nil), compileURLDefaultSchemes...),
 	}
 )
 
@@ -60,29 +128,71 @@ func (o *Options) clone() *Options {
 		return o
 	}
 
+	var allowedHTMLTags map[string][]string
+	if o.AllowedHTMLTags != nil {
+		allowedHTMLTags = make(map[string][]string, len(o.AllowedHTMLTags))
+		for tag, attributes := range o.AllowedHTMLTags {
+			allowedHTMLTags[tag] = append([]string(nil), attributes...)
+		}
+	}
+
+	var allowedURLSchemes []string
+	if o.AllowedURLSchemes != nil {
+		allowedURLSchemes = make([]string, len(o.AllowedURLSchemes))
+		copy(allowedURLSchemes, o.AllowedURLSchemes)
+	}
+
 	return &Options{
 		AllowHTML:                 o.AllowHTML,
 		CleanEmptyTags:            o.CleanEmptyTags,
 		CleanEmptyTokens:          o.CleanEmptyTokens,
 		DebugPrintOutput:          o.DebugPrintOutput,
 		DebugPrintTokens:          o.DebugPrintTokens,
+		DisallowRelativeURLs:      o.DisallowRelativeURLs,
+		EnableAutolinks:           o.EnableAutolinks,
+		EnableBackslashEscapes:    o.EnableBackslashEscapes,
 		EnableBackslashTransforms: o.EnableBackslashTransforms,
 		EnableBlockquotes:         o.EnableBlockquotes,
 		EnableCodeTags:            o.EnableCodeTags,
+		EnableDelTags:             o.EnableDelTags,
 		EnableDocumentTags:        o.EnableDocumentTags,
 		EnableEmTags:              o.EnableEmTags,
+		EnableFencedCodeBlocks:    o.EnableFencedCodeBlocks,
+		EnableFootnotes:           o.EnableFootnotes,
+		EnableFrontMatter:         o.EnableFrontMatter,
+		EnableHeadingAnchors:      o.EnableHeadingAnchors,
+		EnableHeadingIDs:          o.EnableHeadingIDs,
 		EnableHeadings:            o.EnableHeadings,
 		EnableHorizontalRules:     o.EnableHorizontalRules,
 		EnableHyphenTransforms:    o.EnableHyphenTransforms,
 		EnableImages:              o.EnableImages,
+		EnableIndentedCodeBlocks:  o.EnableIndentedCodeBlocks,
+		EnableInsTags:             o.EnableInsTags,
 		EnableLinks:               o.EnableLinks,
 		EnableLists:               o.EnableLists,
+		EnableMarkTags:            o.EnableMarkTags,
+		EnableOrderedLists:        o.EnableOrderedLists,
 		EnableParagraphs:          o.EnableParagraphs,
 		EnableStrongTags:          o.EnableStrongTags,
+		EnableSubTags:             o.EnableSubTags,
+		EnableSupTags:             o.EnableSupTags,
+		EnableTableOfContents:     o.EnableTableOfContents,
+		EnableTables:              o.EnableTables,
+		EnableTaskLists:           o.EnableTaskLists,
+		EnableTypography:          o.EnableTypography,
+		OrderedTableOfContents:    o.OrderedTableOfContents,
+		RenderUnsafeURLsAsText:    o.RenderUnsafeURLsAsText,
+		SanitizeHTML:              o.SanitizeHTML,
+		LineBreakMode:             o.LineBreakMode,
+		TypographyLocale:          o.TypographyLocale,
 		MaxConsecutiveTabs:        o.MaxConsecutiveTabs,
 		MaxConsecutiveSpaces:      o.MaxConsecutiveSpaces,
 		SpacesToTab:               o.SpacesToTab,
 		TabToSpaces:               o.TabToSpaces,
+		AllowedHTMLTags:           allowedHTMLTags,
+		AllowedURLSchemes:         allowedURLSchemes,
+		HeadingSlugFunc:           o.HeadingSlugFunc,
+		Renderer:                  o.Renderer,
 		isCloned:                  true,
 	}
 }
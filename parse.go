@@ -0,0 +1,1035 @@
+package slimdown
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/theTardigrade/golang-slimdown/internal/debug"
+	"github.com/theTardigrade/golang-slimdown/internal/tokenization"
+)
+
+func ParseStringDefault(input string) (doc *Document, err error) {
+	return ParseString(input, nil)
+}
+
+func ParseDefault(input []byte) (doc *Document, err error) {
+	return Parse(input, nil)
+}
+
+func ParseString(input string, options *Options) (doc *Document, err error) {
+	return Parse([]byte(input), options)
+}
+
+func Parse(input []byte, options *Options) (doc *Document, err error) {
+	if options == nil || options == &DefaultOptions {
+		options = DefaultOptions.clone()
+	}
+
+	return parseDocument(input, options, parseContextNew())
+}
+
+// A context is shared by the parts of a document compiled in parts, so that
+// they do not repeat heading ids or footnotes, and can use the links defined
+// in earlier parts.
+type parseContext struct {
+	headingIDs      map[string]int
+	linkDefinitions map[string]compileTokenizeLinkDefinition
+	footnotes       *parseFootnoteState
+}
+
+func parseContextNew() *parseContext {
+	return &parseContext{
+		headingIDs:      make(map[string]int),
+		linkDefinitions: make(map[string]compileTokenizeLinkDefinition),
+		footnotes:       parseFootnoteStateNew(),
+	}
+}
+
+func parseDocument(input []byte, options *Options, context *parseContext) (doc *Document, err error) {
+	var frontMatter map[string]interface{}
+	var rawFrontMatter []byte
+	var bodyIndex int
+
+	if options.EnableFrontMatter {
+		frontMatter, rawFrontMatter, bodyIndex = compileFrontMatter(input)
+	}
+
+	tokens := tokenization.TokenListCollectionNew(input[bodyIndex:])
+
+	defer func() {
+		if e, ok := err.(*CompileError); ok && bodyIndex > 0 {
+			e.shift(bodyIndex, bytes.Count(input[:bodyIndex], []byte{'\n'}))
+		}
+	}()
+
+	if err = compileTokenize(options, tokens, context.linkDefinitions); err != nil {
+		return
+	}
+
+	if options.CleanEmptyTokens {
+		compileCleanEmptyTokens(tokens)
+	}
+
+	if options.DebugPrintTokens {
+		debug.PrintTokens(tokens)
+	}
+
+	doc = DocumentNew(input)
+	doc.FrontMatter = frontMatter
+	doc.RawFrontMatter = rawFrontMatter
+
+	if err = parseTokens(options, tokens, doc.Root, context); err != nil {
+		doc = nil
+		return
+	}
+
+	if !context.footnotes.isDeferred {
+		doc.UnreferencedFootnotes = context.footnotes.unreferenced()
+	}
+
+	if bodyIndex > 0 {
+		for _, c := range doc.Root.Children {
+			parseShiftNode(c, bodyIndex)
+		}
+	}
+
+	return
+}
+
+type parseStackEntry struct {
+	token     *tokenization.Token
+	outerNode *Node
+	innerNode *Node
+}
+
+type parseState struct {
+	options      *Options
+	stack        []parseStackEntry
+	node         *Node
+	openHTMLTags []string
+}
+
+func parseTokens(options *Options, tokens *tokenization.TokenListCollection, root *Node, context *parseContext) (err error) {
+	s := &parseState{
+		options: options,
+		node:    root,
+	}
+
+	for t := tokens.HeadToken; t != nil; t = t.RawNext {
+		if err = parseToken(s, t); err != nil {
+			return
+		}
+	}
+
+	for l := len(s.stack); l > 0; l = len(s.stack) {
+		e := s.stack[l-1]
+		s.stack = s.stack[:l-1]
+
+		parseUnwindStackEntry(e)
+	}
+
+	// sanitized tags that are still open are closed, so that they do not leak into the rest of the page
+	if len(s.openHTMLTags) > 0 {
+		n := NodeNew(NodeKindHTML)
+		n.Text = compileHTMLCloseTags(s.openHTMLTags)
+		root.AppendChild(n)
+	}
+
+	if options.EnableTypography {
+		parseTypography(root, options, tokens.Input)
+	}
+
+	if options.EnableHeadingIDs || options.EnableTableOfContents {
+		// footnote references have no text until they are numbered, so they are left out of the slugs
+		parseHeadingIDs(root, options, context.headingIDs)
+	}
+
+	parseFootnotes(root, context.footnotes)
+
+	if options.EnableTableOfContents {
+		parseTableOfContents(root, options)
+	}
+
+	parseNormalizeNode(root)
+
+	return
+}
+
+func parseToken(s *parseState, t *tokenization.Token) (err error) {
+	switch y := t.Type; y {
+	case tokenization.TokenTypeEmpty,
+		tokenization.TokenTypeStart,
+		tokenization.TokenTypeEnd,
+		tokenization.TokenTypeCarriageReturn,
+		tokenization.TokenTypeDocumentDoctype,
+		tokenization.TokenTypeDocumentHTMLBound,
+		tokenization.TokenTypeDocumentHeadBound,
+		tokenization.TokenTypeDocumentBodyBound:
+		// noop
+	case tokenization.TokenTypeTextGroup:
+		if s.options.AllowHTML {
+			parseAppendLeaf(s, t, NodeKindHTML, t.String())
+			break
+		}
+
+		parseAppendText(s, t, t.String())
+	case tokenization.TokenTypeHTMLTag:
+		parseHTMLTag(s, t)
+	case tokenization.TokenTypeSpaceGroup:
+		parseAppendText(s, t, strings.Repeat(" ", t.Len()))
+	case tokenization.TokenTypeTabGroup:
+		parseAppendText(s, t, strings.Repeat("\t", t.Len()))
+	case tokenization.TokenTypeSpaceHair:
+		parseAppendText(s, t, "\u200a")
+	case tokenization.TokenTypeDashEm:
+		parseAppendText(s, t, "—")
+	case tokenization.TokenTypeDashEn:
+		parseAppendText(s, t, "–")
+	case tokenization.TokenTypeBackslashEscape:
+		if parseStackContainsType(s, tokenization.TokenTypeBacktick) {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseAppendText(s, t, string(compileTokenizeUnescapedBytes(t)))
+	case tokenization.TokenTypeAngleBracketOpen:
+		parseAppendText(s, t, "<")
+	case tokenization.TokenTypeAngleBracketClose:
+		parseAppendText(s, t, ">")
+	case tokenization.TokenTypeBackslash,
+		tokenization.TokenTypeParenthesisOpen,
+		tokenization.TokenTypeParenthesisClose,
+		tokenization.TokenTypeSquareBracketOpen,
+		tokenization.TokenTypeSquareBracketClose,
+		tokenization.TokenTypeExclamation,
+		tokenization.TokenTypeHash,
+		tokenization.TokenTypeHashDouble,
+		tokenization.TokenTypeHashTriple,
+		tokenization.TokenTypeHashQuadruple,
+		tokenization.TokenTypeHashQuintuple,
+		tokenization.TokenTypeHashSextuple,
+		tokenization.TokenTypeHyphen,
+		tokenization.TokenTypeHyphenDouble,
+		tokenization.TokenTypeHyphenTriple,
+		tokenization.TokenTypePipe:
+		parseAppendBytes(s, t)
+	case tokenization.TokenTypeHeading1Bound,
+		tokenization.TokenTypeHeading2Bound,
+		tokenization.TokenTypeHeading3Bound,
+		tokenization.TokenTypeHeading4Bound,
+		tokenization.TokenTypeHeading5Bound,
+		tokenization.TokenTypeHeading6Bound,
+		tokenization.TokenTypeBlockquoteBound:
+		parseTag(s, t)
+	case tokenization.TokenTypeParagraphBound:
+		if !s.options.EnableParagraphs {
+			parseAppendText(s, t, "\n")
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeLineBreak:
+		if !s.options.EnableParagraphs {
+			parseAppendText(s, t, "\n")
+			break
+		}
+
+		parseSingleTag(s, t)
+	case tokenization.TokenTypeLineBreakSoft:
+		if s.options.LineBreakMode == LineBreakModeSpace {
+			parseAppendText(s, t, " ")
+			break
+		}
+
+		parseAppendText(s, t, "\n")
+	case tokenization.TokenTypeBacktickDouble:
+		if s.options.EnableCodeTags && parseStackContainsType(s, tokenization.TokenTypeBacktick) {
+			parseAppendText(s, t, "`")
+			break
+		}
+
+		parseAppendText(s, t, "``")
+	case tokenization.TokenTypeBacktick:
+		if !s.options.EnableCodeTags {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		if next := t.Next(); next != nil && next.Type == y {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		if prev := t.Prev(); prev != nil && prev.Type == y {
+			if prevPrev := prev.Prev(); prevPrev == nil || prevPrev.Type != y {
+				break
+			}
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeUnderscoreTriple, tokenization.TokenTypeAsteriskTriple:
+		if s.options.EnableStrongTags && s.options.EnableEmTags {
+			parseTag(s, t)
+			break
+		}
+
+		fallthrough
+	case tokenization.TokenTypeUnderscoreDouble, tokenization.TokenTypeAsteriskDouble:
+		if !s.options.EnableStrongTags {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeUnderscore, tokenization.TokenTypeAsterisk:
+		if !s.options.EnableEmTags {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeHorizontalRule:
+		if !s.options.EnableHorizontalRules {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseSingleTag(s, t)
+	case tokenization.TokenTypeEqualsDouble:
+		if !s.options.EnableMarkTags {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeTildeDouble:
+		if !s.options.EnableDelTags {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypePlusDouble:
+		if !s.options.EnableInsTags {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeTilde:
+		if !s.options.EnableSubTags {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeCaret:
+		if !s.options.EnableSupTags {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeLinkBound:
+		if !s.options.EnableLinks {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeImageBound:
+		if !s.options.EnableImages {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeFootnoteReference, tokenization.TokenTypeTaskCheckbox:
+		parseSingleTag(s, t)
+	case tokenization.TokenTypeFootnoteDefinitionBound:
+		parseTag(s, t)
+	case tokenization.TokenTypeUnorderedListBound:
+		if !s.options.EnableLists {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeOrderedListBound:
+		if !s.options.EnableOrderedLists {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeListItemBound:
+		if !s.options.EnableLists && !s.options.EnableOrderedLists {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeCodeBlockBound:
+		if !s.options.EnableFencedCodeBlocks && !s.options.EnableIndentedCodeBlocks {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	case tokenization.TokenTypeCodeBlockText:
+		parseAppendText(s, t, parseCodeBlockText(t))
+	case tokenization.TokenTypeTableBound,
+		tokenization.TokenTypeTableHeadBound,
+		tokenization.TokenTypeTableBodyBound,
+		tokenization.TokenTypeTableRowBound,
+		tokenization.TokenTypeTableHeaderCellBound,
+		tokenization.TokenTypeTableCellBound:
+		if !s.options.EnableTables {
+			parseAppendBytes(s, t)
+			break
+		}
+
+		parseTag(s, t)
+	default:
+		err = compileErrorNew(ErrCompileTokenTypeUnknown, "parse", t)
+	}
+
+	return
+}
+
+func parseCodeBlockText(t *tokenization.Token) string {
+	b := t.Bytes()
+
+	var builder strings.Builder
+
+	for len(b) > 0 {
+		for column := 0; column < t.Indent && len(b) > 0; b = b[1:] {
+			if b[0] == ' ' {
+				column++
+			} else if tabStop := column + parseCodeBlockTabWidth - column%parseCodeBlockTabWidth; b[0] == '\t' && tabStop <= t.Indent {
+				column = tabStop
+			} else {
+				break
+			}
+		}
+
+		lineLen := strings.IndexByte(string(b), '\n') + 1
+		if lineLen <= 0 {
+			lineLen = len(b)
+		}
+
+		builder.Write(b[:lineLen])
+		b = b[lineLen:]
+	}
+
+	// the last line of a block that ends the input has no line break of its own
+	if l := builder.Len(); l > 0 && builder.String()[l-1] != '\n' {
+		builder.WriteByte('\n')
+	}
+
+	return builder.String()
+}
+
+func parseHTMLTag(s *parseState, t *tokenization.Token) {
+	if parseStackContainsType(s, tokenization.TokenTypeBacktick) {
+		parseAppendBytes(s, t)
+		return
+	}
+
+	if !s.options.SanitizeHTML {
+		parseAppendLeaf(s, t, NodeKindHTML, t.String())
+		return
+	}
+
+	if tag, ok := compileHTMLFindTag(t.Bytes(), 0); ok {
+		if output, ok := compileHTMLSanitizeTag(tag, s.options); ok {
+			if output, ok = compileHTMLBalanceTag(tag, output, &s.openHTMLTags); ok {
+				parseAppendLeaf(s, t, NodeKindHTML, output)
+			}
+
+			return
+		}
+	}
+
+	parseAppendBytes(s, t)
+}
+
+func parseStackContainsType(s *parseState, y tokenization.TokenType) bool {
+	for _, e := range s.stack {
+		if e.token.Type == y {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseAppendBytes(s *parseState, t *tokenization.Token) {
+	parseAppendText(s, t, t.String())
+}
+
+func parseAppendText(s *parseState, t *tokenization.Token, text string) {
+	parseAppendLeaf(s, t, NodeKindText, text)
+}
+
+func parseAppendLeaf(s *parseState, t *tokenization.Token, kind NodeKind, text string) {
+	if text == "" {
+		return
+	}
+
+	n := NodeNew(kind)
+	n.Text = text
+	parseSetNodeSpan(n, t)
+
+	s.node.AppendChild(n)
+}
+
+func parseSetNodeSpan(n *Node, t *tokenization.Token) {
+	start, end := t.InputStartIndex, t.InputEndIndex
+
+	if end <= start || t.ListCollection == nil || end > len(t.ListCollection.Input) {
+		return
+	}
+
+	if n.Kind != NodeKindText && n.Kind != NodeKindHTML {
+		if len(bytes.Trim(t.ListCollection.Input[start:end], "\r\n")) == 0 {
+			return
+		}
+	}
+
+	if n.StartIndex < 0 || start < n.StartIndex {
+		n.StartIndex = start
+	}
+
+	if end > n.EndIndex {
+		n.EndIndex = end
+	}
+}
+
+// Kinds lists the nodes that a token type opens, from the outermost inwards.
+type parseTokenTypeDatum struct {
+	Kinds []NodeKind
+	Level int
+}
+
+var (
+	parseTokenTypeData = map[tokenization.TokenType]parseTokenTypeDatum{
+		tokenization.TokenTypeParagraphBound:          {Kinds: []NodeKind{NodeKindParagraph}},
+		tokenization.TokenTypeHeading1Bound:           {Kinds: []NodeKind{NodeKindHeading}, Level: 1},
+		tokenization.TokenTypeHeading2Bound:           {Kinds: []NodeKind{NodeKindHeading}, Level: 2},
+		tokenization.TokenTypeHeading3Bound:           {Kinds: []NodeKind{NodeKindHeading}, Level: 3},
+		tokenization.TokenTypeHeading4Bound:           {Kinds: []NodeKind{NodeKindHeading}, Level: 4},
+		tokenization.TokenTypeHeading5Bound:           {Kinds: []NodeKind{NodeKindHeading}, Level: 5},
+		tokenization.TokenTypeHeading6Bound:           {Kinds: []NodeKind{NodeKindHeading}, Level: 6},
+		tokenization.TokenTypeBlockquoteBound:         {Kinds: []NodeKind{NodeKindBlockquote}},
+		tokenization.TokenTypeUnorderedListBound:      {Kinds: []NodeKind{NodeKindUnorderedList}},
+		tokenization.TokenTypeOrderedListBound:        {Kinds: []NodeKind{NodeKindOrderedList}},
+		tokenization.TokenTypeListItemBound:           {Kinds: []NodeKind{NodeKindListItem}},
+		tokenization.TokenTypeCodeBlockBound:          {Kinds: []NodeKind{NodeKindCodeBlock}},
+		tokenization.TokenTypeHorizontalRule:          {Kinds: []NodeKind{NodeKindHorizontalRule}},
+		tokenization.TokenTypeTableBound:              {Kinds: []NodeKind{NodeKindTable}},
+		tokenization.TokenTypeTableHeadBound:          {Kinds: []NodeKind{NodeKindTableHead}},
+		tokenization.TokenTypeTableBodyBound:          {Kinds: []NodeKind{NodeKindTableBody}},
+		tokenization.TokenTypeTableRowBound:           {Kinds: []NodeKind{NodeKindTableRow}},
+		tokenization.TokenTypeTableHeaderCellBound:    {Kinds: []NodeKind{NodeKindTableHeaderCell}},
+		tokenization.TokenTypeTableCellBound:          {Kinds: []NodeKind{NodeKindTableCell}},
+		tokenization.TokenTypeLineBreak:               {Kinds: []NodeKind{NodeKindLineBreak}},
+		tokenization.TokenTypeAsterisk:                {Kinds: []NodeKind{NodeKindEmphasis}},
+		tokenization.TokenTypeAsteriskDouble:          {Kinds: []NodeKind{NodeKindStrong}},
+		tokenization.TokenTypeAsteriskTriple:          {Kinds: []NodeKind{NodeKindStrong, NodeKindEmphasis}},
+		tokenization.TokenTypeUnderscore:              {Kinds: []NodeKind{NodeKindEmphasis}},
+		tokenization.TokenTypeUnderscoreDouble:        {Kinds: []NodeKind{NodeKindStrong}},
+		tokenization.TokenTypeUnderscoreTriple:        {Kinds: []NodeKind{NodeKindStrong, NodeKindEmphasis}},
+		tokenization.TokenTypeEqualsDouble:            {Kinds: []NodeKind{NodeKindMark}},
+		tokenization.TokenTypeTildeDouble:             {Kinds: []NodeKind{NodeKindDelete}},
+		tokenization.TokenTypePlusDouble:              {Kinds: []NodeKind{NodeKindInsert}},
+		tokenization.TokenTypeTilde:                   {Kinds: []NodeKind{NodeKindSubscript}},
+		tokenization.TokenTypeCaret:                   {Kinds: []NodeKind{NodeKindSuperscript}},
+		tokenization.TokenTypeBacktick:                {Kinds: []NodeKind{NodeKindCode}},
+		tokenization.TokenTypeLinkBound:               {Kinds: []NodeKind{NodeKindLink}},
+		tokenization.TokenTypeImageBound:              {Kinds: []NodeKind{NodeKindImage}},
+		tokenization.TokenTypeFootnoteReference:       {Kinds: []NodeKind{NodeKindFootnoteReference}},
+		tokenization.TokenTypeTaskCheckbox:            {Kinds: []NodeKind{NodeKindTaskCheckbox}},
+		tokenization.TokenTypeFootnoteDefinitionBound: {Kinds: []NodeKind{NodeKindFootnote}},
+	}
+)
+
+func parseNodesNew(t *tokenization.Token) (outerNode, innerNode *Node) {
+	datum := parseTokenTypeData[t.Type]
+
+	for _, kind := range datum.Kinds {
+		n := NodeNew(kind)
+		n.Level = datum.Level
+
+		if innerNode == nil {
+			outerNode = n
+		} else {
+			innerNode.AppendChild(n)
+		}
+
+		innerNode = n
+	}
+
+	if outerNode == nil {
+		outerNode = NodeNew(NodeKindDocument)
+		innerNode = outerNode
+	}
+
+	if l := len(t.Attributes); l > 0 {
+		outerNode.Attributes = make(map[string]string, l)
+
+		for k, v := range t.Attributes {
+			outerNode.Attributes[k] = v
+		}
+	}
+
+	parseSetNodeSpan(outerNode, t)
+
+	return
+}
+
+func parseSingleTag(s *parseState, t *tokenization.Token) {
+	if parseStackContainsType(s, tokenization.TokenTypeBacktick) {
+		parseAppendBytes(s, t)
+		return
+	}
+
+	n, _ := parseNodesNew(t)
+
+	s.node.AppendChild(n)
+}
+
+func parseTag(s *parseState, t *tokenization.Token) {
+	if i := parseStackIndexOfOpener(s, t); i >= 0 {
+		for l := len(s.stack) - 1; l > i; l-- {
+			parseUnwindStackEntry(s.stack[l])
+		}
+
+		e := s.stack[i]
+		s.stack = s.stack[:i]
+		s.node = e.outerNode.Parent
+
+		parseSetNodeSpan(e.outerNode, t)
+
+		return
+	}
+
+	if parseStackContainsType(s, tokenization.TokenTypeBacktick) {
+		parseAppendBytes(s, t)
+		return
+	}
+
+	outerNode, innerNode := parseNodesNew(t)
+
+	s.node.AppendChild(outerNode)
+	s.node = innerNode
+	s.stack = append(s.stack, parseStackEntry{
+		token:     t,
+		outerNode: outerNode,
+		innerNode: innerNode,
+	})
+}
+
+// A block closer also closes any unmatched inline openers above it, which are unwound as text.
+func parseStackIndexOfOpener(s *parseState, t *tokenization.Token) int {
+	for i := len(s.stack) - 1; i >= 0; i-- {
+		e := s.stack[i]
+
+		if e.token.Type == t.Type && e.token.Indent == t.Indent {
+			if i == len(s.stack)-1 || e.outerNode.Kind.IsBlock() {
+				return i
+			}
+
+			break
+		}
+
+		if e.outerNode.Kind.IsBlock() {
+			break
+		}
+	}
+
+	return -1
+}
+
+func parseUnwindStackEntry(e parseStackEntry) {
+	n := NodeNew(NodeKindText)
+	n.Text = e.token.String()
+	parseSetNodeSpan(n, e.token)
+
+	nodes := []*Node{n}
+	nodes = append(nodes, e.innerNode.Children...)
+
+	e.outerNode.ReplaceWith(nodes...)
+}
+
+const (
+	parseCodeBlockTabWidth          = 4
+	parseFootnoteBackReferenceClass = "footnote-backref"
+	parseHeadingAnchorClass         = "heading-anchor"
+	parseHeadingDefaultSlug         = "section"
+	parseTableOfContentsClass       = "table-of-contents"
+	parseTableOfContentsPlaceholder = "[[toc]]"
+)
+
+// Footnotes are numbered in the order that they are first referenced, and
+// moved into a section at the end of the document; the ids derive from the
+// labels, so they do not change when other footnotes are added. Labels are
+// matched as link labels are, regardless of case.
+type parseFootnoteState struct {
+	definitions map[string]*Node
+	// the labels as written, in the order that they are defined
+	definedLabels   []string
+	labels          []string
+	levels          map[string]int
+	referenceCounts map[string]int
+	// the parts of a document compiled in parts may reference footnotes that
+	// are only defined in a later part, so their section is built at the end
+	isDeferred bool
+}
+
+func parseFootnoteStateNew() *parseFootnoteState {
+	return &parseFootnoteState{
+		definitions:     make(map[string]*Node),
+		levels:          make(map[string]int),
+		referenceCounts: make(map[string]int),
+	}
+}
+
+func parseFootnotes(root *Node, s *parseFootnoteState) {
+	for _, n := range root.FindAll(NodeKindFootnote) {
+		rawLabel, _ := n.Attribute("label")
+		label := compileTokenizeLinksNormalizeLabel(rawLabel)
+
+		n.Remove()
+
+		if _, ok := s.definitions[label]; !ok {
+			s.definitions[label] = n
+			s.definedLabels = append(s.definedLabels, rawLabel)
+		}
+	}
+
+	s.reference(root)
+
+	if s.isDeferred {
+		return
+	}
+
+	if section := s.section(); section != nil {
+		root.AppendChild(section)
+	}
+}
+
+func (s *parseFootnoteState) reference(parent *Node) {
+	for _, n := range parent.FindAll(NodeKindFootnoteReference) {
+		rawLabel, _ := n.Attribute("label")
+		label := compileTokenizeLinksNormalizeLabel(rawLabel)
+
+		level, ok := s.levels[label]
+		if !ok {
+			if _, ok = s.definitions[label]; !ok && !s.isDeferred {
+				text := TextNodeNew("[^" + rawLabel + "]")
+				text.StartIndex, text.EndIndex = n.StartIndex, n.EndIndex
+				n.ReplaceWith(text)
+				continue
+			}
+
+			s.labels = append(s.labels, label)
+			level = len(s.labels)
+			s.levels[label] = level
+		}
+
+		s.referenceCounts[label]++
+
+		n.Level = level
+		n.Attributes = map[string]string{
+			"href": "#fn-" + label,
+			"id":   parseFootnoteReferenceID(label, s.referenceCounts[label]),
+		}
+		n.AppendChild(TextNodeNew(strconv.Itoa(level)))
+	}
+}
+
+// The section lists the footnotes that have been referenced and defined,
+// each followed by a link back to every reference to it.
+func (s *parseFootnoteState) section() (section *Node) {
+	var labels []string
+	var footnotes []*Node
+
+	// every definition has been read by now
+	s.isDeferred = false
+
+	// footnotes may reference further footnotes, which are appended as they are found
+	for i := 0; i < len(s.labels); i++ {
+		label := s.labels[i]
+
+		footnote, ok := s.definitions[label]
+		if !ok {
+			continue
+		}
+
+		footnote.Level = s.levels[label]
+		footnote.Attributes = map[string]string{"id": "fn-" + label}
+		s.reference(footnote)
+
+		labels = append(labels, label)
+		footnotes = append(footnotes, footnote)
+	}
+
+	if len(footnotes) == 0 {
+		return
+	}
+
+	section = NodeNew(NodeKindFootnotes)
+	section.SetAttribute("class", "footnotes")
+
+	for i, footnote := range footnotes {
+		label := labels[i]
+
+		for count := 1; count <= s.referenceCounts[label]; count++ {
+			backReferenceText := "↩"
+			if count > 1 {
+				backReferenceText += strconv.Itoa(count)
+			}
+
+			backReference := NodeNew(NodeKindLink)
+			backReference.Attributes = map[string]string{
+				"class": parseFootnoteBackReferenceClass,
+				"href":  "#" + parseFootnoteReferenceID(label, count),
+			}
+			backReference.AppendChild(TextNodeNew(backReferenceText))
+
+			footnote.AppendChild(TextNodeNew(" "), backReference)
+		}
+
+		section.AppendChild(footnote)
+	}
+
+	return
+}
+
+// Definitions that nothing references are left out of the section, as they
+// have no number; their labels are returned so that callers can report them.
+func (s *parseFootnoteState) unreferenced() (labels []string) {
+	for _, rawLabel := range s.definedLabels {
+		if _, ok := s.levels[compileTokenizeLinksNormalizeLabel(rawLabel)]; !ok {
+			labels = append(labels, rawLabel)
+		}
+	}
+
+	return
+}
+
+func parseFootnoteReferenceID(label string, count int) (id string) {
+	id = "fnref-" + label
+	if count > 1 {
+		id += "-" + strconv.Itoa(count)
+	}
+
+	return
+}
+
+func parseHeadingIDs(root *Node, options *Options, headingIDs map[string]int) {
+	slugFunc := options.HeadingSlugFunc
+	if slugFunc == nil {
+		slugFunc = parseHeadingSlug
+	}
+
+	for _, n := range root.FindAll(NodeKindHeading) {
+		slug := slugFunc(n.TextContent())
+		if slug == "" {
+			slug = parseHeadingDefaultSlug
+		}
+
+		id := slug
+
+		if count, ok := headingIDs[slug]; ok {
+			for {
+				count++
+				id = slug + "-" + strconv.Itoa(count)
+
+				if _, ok = headingIDs[id]; !ok {
+					break
+				}
+			}
+
+			headingIDs[slug] = count
+		}
+
+		headingIDs[id] = 0
+
+		n.SetAttribute("id", id)
+
+		if options.EnableHeadingAnchors {
+			anchor := NodeNew(NodeKindLink)
+			anchor.Attributes = map[string]string{
+				"aria-hidden": "true",
+				"class":       parseHeadingAnchorClass,
+				"href":        "#" + id,
+			}
+			anchor.AppendChild(TextNodeNew("#"))
+
+			if first := n.FirstChild(); first != nil {
+				n.InsertBefore(first, anchor)
+			} else {
+				n.AppendChild(anchor)
+			}
+		}
+	}
+}
+
+// Letters and digits from any script are kept, in lower case,
+// and every run of spaces and hyphens becomes a single hyphen.
+func parseHeadingSlug(text string) string {
+	var builder strings.Builder
+	var hasHyphen bool
+
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == '_':
+			if hasHyphen && builder.Len() > 0 {
+				builder.WriteByte('-')
+			}
+			hasHyphen = false
+
+			builder.WriteRune(unicode.ToLower(r))
+		case unicode.IsSpace(r) || r == '-':
+			hasHyphen = true
+		}
+	}
+
+	return builder.String()
+}
+
+// A paragraph holding nothing but the placeholder is replaced by the list.
+func parseTableOfContents(root *Node, options *Options) {
+	var entries []TableOfContentsEntry
+	var hasEntries bool
+
+	for _, n := range root.FindAll(NodeKindParagraph) {
+		if !parseIsTableOfContentsPlaceholder(n) {
+			continue
+		}
+
+		if !hasEntries {
+			entries = documentTableOfContents(root)
+			hasEntries = true
+		}
+
+		list := parseTableOfContentsList(entries, options)
+		list.SetAttribute("class", parseTableOfContentsClass)
+		list.StartIndex, list.EndIndex = n.StartIndex, n.EndIndex
+
+		n.ReplaceWith(list)
+	}
+}
+
+func parseIsTableOfContentsPlaceholder(n *Node) bool {
+	for _, c := range n.Children {
+		if c.Kind != NodeKindText {
+			return false
+		}
+	}
+
+	return strings.EqualFold(strings.TrimSpace(n.TextContent()), parseTableOfContentsPlaceholder)
+}
+
+// Headings that skip a level are nested one level deeper than the heading
+// before them, and headings above the first heading's level stay at the top.
+// A heading between two levels becomes a sibling of the deeper one.
+func parseTableOfContentsList(entries []TableOfContentsEntry, options *Options) *Node {
+	listKind := NodeKindUnorderedList
+	if options.OrderedTableOfContents {
+		listKind = NodeKindOrderedList
+	}
+
+	lists := []*Node{NodeNew(listKind)}
+	var levels []int
+
+	for _, e := range entries {
+		for len(levels) > 1 && e.Level <= levels[len(levels)-2] {
+			lists = lists[:len(lists)-1]
+			levels = levels[:len(levels)-1]
+		}
+
+		list := lists[len(lists)-1]
+
+		switch l := len(levels); {
+		case l == 0:
+			levels = append(levels, e.Level)
+		case e.Level > levels[l-1] && list.LastChild() != nil:
+			sublist := NodeNew(listKind)
+			list.LastChild().AppendChild(sublist)
+
+			list = sublist
+			lists = append(lists, list)
+			levels = append(levels, e.Level)
+		case e.Level < levels[l-1]:
+			levels[l-1] = e.Level
+		}
+
+		link := NodeNew(NodeKindLink)
+		link.SetAttribute("href", "#"+e.ID)
+		link.AppendChild(TextNodeNew(e.Text))
+
+		item := NodeNew(NodeKindListItem)
+		item.AppendChild(link)
+
+		list.AppendChild(item)
+	}
+
+	return lists[0]
+}
+
+func parseShiftNode(n *Node, offset int) {
+	n.Walk(func(n2 *Node) bool {
+		if n2.StartIndex >= 0 {
+			n2.StartIndex += offset
+		}
+
+		if n2.EndIndex >= 0 {
+			n2.EndIndex += offset
+		}
+
+		return true
+	})
+}
+
+func parseNormalizeNode(n *Node) {
+	var children []*Node
+
+	for _, c := range n.Children {
+		parseNormalizeNode(c)
+
+		if c.StartIndex >= 0 && (n.StartIndex < 0 || c.StartIndex < n.StartIndex) {
+			n.StartIndex = c.StartIndex
+		}
+
+		if c.EndIndex > n.EndIndex {
+			n.EndIndex = c.EndIndex
+		}
+
+		if l := len(children); l > 0 {
+			if prev := children[l-1]; prev.Kind == c.Kind && (c.Kind == NodeKindText || c.Kind == NodeKindHTML) {
+				prev.Text += c.Text
+
+				if c.StartIndex >= 0 && (prev.StartIndex < 0 || c.StartIndex < prev.StartIndex) {
+					prev.StartIndex = c.StartIndex
+				}
+
+				if c.EndIndex > prev.EndIndex {
+					prev.EndIndex = c.EndIndex
+				}
+
+				c.Parent = nil
+
+				continue
+			}
+		}
+
+		children = append(children, c)
+	}
+
+	n.Children = children
+}
@@ -0,0 +1,169 @@
+package slimdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse_headings(t *testing.T) {
+	const input = "# First\n\nText.\n\n## Second *part*"
+
+	doc, err := ParseString(input, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	headings := doc.Root.FindAll(NodeKindHeading)
+	if assert.Len(t, headings, 2) {
+		assert.Equal(t, 1, headings[0].Level)
+		assert.Equal(t, "First", headings[0].TextContent())
+		assert.Equal(t, 2, headings[1].Level)
+		assert.Equal(t, "Second part", headings[1].TextContent())
+
+		start, end := headings[1].StartIndex, headings[1].EndIndex
+		assert.Equal(t, "Second *part*", input[start:end])
+	}
+}
+
+func TestParse_modifyAndRender(t *testing.T) {
+	doc, err := ParseString("Read the docs at <http://old.example.com/docs> now.", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, n := range doc.Root.FindAll(NodeKindLink) {
+		n.SetAttribute("href", "https://new.example.com/docs")
+	}
+
+	for _, n := range doc.Root.FindAll(NodeKindParagraph) {
+		n.AppendChild(TextNodeNew(" Thanks."))
+	}
+
+	output, err := Render(doc, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(
+		t,
+		`<p>Read the docs at <a href="https://new.example.com/docs">http://old.example.com/docs</a> now. Thanks.</p>`,
+		string(output),
+	)
+}
+
+func TestParse_matchesCompile(t *testing.T) {
+	for key, input := range testCompileStringInput {
+		options := testCompileStringOptions[key]
+
+		doc, err := Parse(input, options)
+		if err != nil {
+			panic(err)
+		}
+
+		output, err := Render(doc, options)
+		if err != nil {
+			panic(err)
+		}
+
+		assert.Equal(t, string(testCompileStringExpectedOutput[key]), string(output), key)
+	}
+}
+
+func TestParse_headingSlugFunc(t *testing.T) {
+	options := DefaultOptions
+	options.EnableHeadingIDs = true
+	options.HeadingSlugFunc = func(text string) string {
+		return "h-" + strings.ToUpper(text)
+	}
+
+	doc, err := ParseString("# One\n\n## One\n\n## Two", &options)
+	if err != nil {
+		panic(err)
+	}
+
+	var ids []string
+
+	for _, n := range doc.Root.FindAll(NodeKindHeading) {
+		id, _ := n.Attribute("id")
+		ids = append(ids, id)
+	}
+
+	assert.Equal(t, []string{"h-ONE", "h-ONE-1", "h-TWO"}, ids)
+}
+
+func TestParse_taskCounts(t *testing.T) {
+	options := DefaultOptions
+	options.EnableOrderedLists = true
+	options.EnableTaskLists = true
+
+	doc, err := ParseString("* [x] one\n* [ ] two\n* three\n\n1. [X] four", &options)
+	if err != nil {
+		panic(err)
+	}
+
+	completed, total := doc.TaskCounts()
+
+	assert.Equal(t, 2, completed)
+	assert.Equal(t, 3, total)
+}
+
+func TestParse_tableOfContents(t *testing.T) {
+	const input = "# Title[^1]\n\n[[TOC]]\n\n## Part *one*\n\n## Part one\n\n[^1]: Note."
+
+	options := DefaultOptions
+	options.EnableFootnotes = true
+	options.EnableHeadingAnchors = true
+	options.EnableTableOfContents = true
+	options.OrderedTableOfContents = true
+
+	doc, err := ParseString(input, &options)
+	if err != nil {
+		panic(err)
+	}
+
+	output, err := Render(doc, &options)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(
+		t,
+		[]TableOfContentsEntry{
+			{Level: 1, Text: "Title", ID: "title"},
+			{Level: 2, Text: "Part one", ID: "part-one"},
+			{Level: 2, Text: "Part one", ID: "part-one-1"},
+		},
+		doc.TableOfContents(),
+	)
+
+	assert.Contains(
+		t,
+		string(output),
+		`<ol class="table-of-contents"><li><a href="#title">Title</a><ol><li><a href="#part-one">Part one</a></li><li><a href="#part-one-1">Part one</a></li></ol></li></ol>`,
+	)
+}
+
+func TestParse_footnoteLabels(t *testing.T) {
+	options := DefaultOptions
+	options.EnableFootnotes = true
+
+	doc, err := ParseString("Text[^Note] and[^note].\n\n[^NOTE]: One.\n[^Spare]: Unused.", &options)
+	if err != nil {
+		panic(err)
+	}
+
+	output, err := Render(doc, &options)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(
+		t,
+		`<p>Text<sup><a href="#fn-note" id="fnref-note">1</a></sup> and<sup><a href="#fn-note" id="fnref-note-2">1</a></sup>.</p>`+
+			`<section class="footnotes"><ol><li id="fn-note">One. <a class="footnote-backref" href="#fnref-note">↩</a> <a class="footnote-backref" href="#fnref-note-2">↩2</a></li></ol></section>`,
+		string(output),
+	)
+	assert.Equal(t, []string{"Spare"}, doc.UnreferencedFootnotes)
+}
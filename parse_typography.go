@@ -0,0 +1,401 @@
+package slimdown
+
+import (
+	"strings"
+	"unicode"
+)
+
+type parseTypographyQuotes struct {
+	doubleOpen  string
+	doubleClose string
+	singleOpen  string
+	singleClose string
+}
+
+const (
+	parseTypographyApostrophe = "’"
+	// the French guillemets are set apart from the quoted text by a narrow no-break space
+	parseTypographyFrenchSpace = "\u202f"
+)
+
+var (
+	parseTypographyLocaleQuotes = map[TypographyLocale]parseTypographyQuotes{
+		TypographyLocaleEnglish: {"“", "”", "‘", "’"},
+		TypographyLocaleGerman:  {"„", "“", "‚", "‘"},
+		TypographyLocaleFrench: {
+			"«" + parseTypographyFrenchSpace, parseTypographyFrenchSpace + "»",
+			"‹" + parseTypographyFrenchSpace, parseTypographyFrenchSpace + "›",
+		},
+	}
+	parseTypographyReplacements = []struct {
+		from string
+		to   string
+	}{
+		{"...", "…"},
+		{"(c)", "©"},
+		{"(r)", "®"},
+		{"(tm)", "™"},
+		{"+-", "±"},
+	}
+	parseTypographyFractions = map[string]string{
+		"1/2": "½",
+		"1/4": "¼",
+		"3/4": "¾",
+	}
+	parseTypographyURLPrefixes = []string{"http://", "https://", "mailto:", "www."}
+)
+
+// Each segment is either a text node that may be rewritten, or an opaque
+// stand-in (a code span, an image, an escaped character) that only lends
+// context to its neighbours.
+type parseTypographySegment struct {
+	node     *Node
+	runes    []rune
+	output   []rune
+	isOpaque bool
+}
+
+type parseTypographyState struct {
+	quotes      parseTypographyQuotes
+	input       []byte
+	segments    []*parseTypographySegment
+	runes       []rune
+	owners      []*parseTypographySegment
+	isURL       []bool
+	openDouble  int
+	openSingle  int
+	openQuoteAt int
+}
+
+func parseTypography(root *Node, options *Options, input []byte) {
+	quotes, ok := parseTypographyLocaleQuotes[options.TypographyLocale]
+	if !ok {
+		quotes = parseTypographyLocaleQuotes[TypographyLocaleEnglish]
+	}
+
+	s := &parseTypographyState{
+		quotes: quotes,
+		input:  input,
+	}
+
+	s.block(root)
+}
+
+// Quotes are paired within a block, so that the inline elements inside it
+// do not break up the context that decides which way a quote faces.
+func (s *parseTypographyState) block(n *Node) {
+	if n.Kind == NodeKindCodeBlock {
+		return
+	}
+
+	outer := s.segments
+	s.segments = nil
+
+	s.collect(n)
+	s.transform()
+
+	s.segments = outer
+}
+
+func (s *parseTypographyState) collect(n *Node) {
+	for _, c := range n.Children {
+		switch c.Kind {
+		case NodeKindText:
+			s.segments = append(s.segments, &parseTypographySegment{
+				node:     c,
+				runes:    []rune(c.Text),
+				isOpaque: s.isEscaped(c),
+			})
+		case NodeKindCode, NodeKindImage, NodeKindFootnoteReference:
+			s.appendOpaque('a')
+		case NodeKindLineBreak, NodeKindTaskCheckbox:
+			s.appendOpaque(' ')
+		case NodeKindHTML:
+			// noop
+		case NodeKindLink:
+			// the text of an autolink is an address, which is left as it is
+			if text := c.TextContent(); !strings.ContainsAny(text, " \t\n") && parseTypographyIsURL(text) {
+				s.appendOpaque('a')
+				break
+			}
+
+			s.collect(c)
+		default:
+			if c.Kind.IsBlock() {
+				s.appendOpaque('\n')
+				s.block(c)
+				s.appendOpaque('\n')
+				break
+			}
+
+			s.collect(c)
+		}
+	}
+}
+
+func (s *parseTypographyState) appendOpaque(r rune) {
+	s.segments = append(s.segments, &parseTypographySegment{
+		runes:    []rune{r},
+		isOpaque: true,
+	})
+}
+
+func (s *parseTypographyState) isEscaped(n *Node) bool {
+	return n.StartIndex >= 0 && n.StartIndex < len(s.input) && s.input[n.StartIndex] == '\\'
+}
+
+func (s *parseTypographyState) transform() {
+	s.runes, s.owners = nil, nil
+	s.openDouble, s.openSingle, s.openQuoteAt = 0, 0, -1
+
+	for _, g := range s.segments {
+		for _, r := range g.runes {
+			s.runes = append(s.runes, r)
+			s.owners = append(s.owners, g)
+		}
+	}
+
+	s.markURLs()
+
+	for i, l := 0, len(s.runes); i < l; i++ {
+		g := s.owners[i]
+
+		if !s.isTransformable(i) {
+			if !g.isOpaque {
+				g.output = append(g.output, s.runes[i])
+			}
+			continue
+		}
+
+		switch r := s.runes[i]; r {
+		case '"':
+			if s.writeQuote(i, s.isDoubleOpening(i), true) {
+				i++
+			}
+			continue
+		case '\'':
+			if isOpening, isQuote := s.singleQuoteKind(i); isQuote {
+				if s.writeQuote(i, isOpening, false) {
+					i++
+				}
+				continue
+			}
+
+			g.output = append(g.output, []rune(parseTypographyApostrophe)...)
+			continue
+		}
+
+		if n := s.replace(i); n > 0 {
+			i += n - 1
+			continue
+		}
+
+		g.output = append(g.output, s.runes[i])
+	}
+
+	for _, g := range s.segments {
+		if !g.isOpaque {
+			g.node.Text = string(g.output)
+		}
+	}
+}
+
+// Words that look like URLs are left alone, so that their quotes, dots and
+// fractions are not rewritten.
+func (s *parseTypographyState) markURLs() {
+	l := len(s.runes)
+	s.isURL = make([]bool, l)
+
+	for i := 0; i < l; {
+		if unicode.IsSpace(s.runes[i]) {
+			i++
+			continue
+		}
+
+		j := i
+		for j < l && !unicode.IsSpace(s.runes[j]) {
+			j++
+		}
+
+		if parseTypographyIsURL(string(s.runes[i:j])) {
+			for k := i; k < j; k++ {
+				s.isURL[k] = true
+			}
+		}
+
+		i = j
+	}
+}
+
+func parseTypographyIsURL(word string) bool {
+	word = strings.ToLower(word)
+
+	if strings.Contains(word, "://") {
+		return true
+	}
+
+	for _, p := range parseTypographyURLPrefixes {
+		if strings.HasPrefix(strings.TrimLeft(word, "(<\"'"), p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *parseTypographyState) isTransformable(i int) bool {
+	return i >= 0 && i < len(s.runes) && !s.owners[i].isOpaque && !s.isURL[i]
+}
+
+func (s *parseTypographyState) rune(i int) rune {
+	if i < 0 || i >= len(s.runes) {
+		return 0
+	}
+
+	return s.runes[i]
+}
+
+func (s *parseTypographyState) isSpaceAt(i int) bool {
+	r := s.rune(i)
+
+	return r == 0 || unicode.IsSpace(r)
+}
+
+func (s *parseTypographyState) isWordAt(i int) bool {
+	r := s.rune(i)
+
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// A quote opens after whitespace, an opening bracket, a dash or another
+// opening quote.
+func (s *parseTypographyState) isOpeningContextAt(i int) bool {
+	if s.isSpaceAt(i) || s.openQuoteAt == i {
+		return true
+	}
+
+	return strings.ContainsRune("([{-–—", s.rune(i))
+}
+
+func (s *parseTypographyState) isDoubleOpening(i int) bool {
+	isAfterOpening := s.isOpeningContextAt(i - 1)
+
+	if !isAfterOpening {
+		return false
+	}
+
+	if !s.isSpaceAt(i+1) && !s.isClosingContextAt(i+1) {
+		return true
+	}
+
+	// a quote with space on both sides closes any open quote
+	return s.openDouble == 0
+}
+
+func (s *parseTypographyState) isClosingContextAt(i int) bool {
+	return strings.ContainsRune(".,;:!?)]}", s.rune(i))
+}
+
+func (s *parseTypographyState) singleQuoteKind(i int) (isOpening, isQuote bool) {
+	switch {
+	case s.isWordAt(i-1) && s.isWordAt(i+1):
+		return
+	case s.isWordAt(i - 1):
+		isQuote = s.openSingle > 0
+		return
+	case !s.isOpeningContextAt(i - 1):
+		isQuote = s.openSingle > 0
+		return
+	case unicode.IsDigit(s.rune(i + 1)):
+		// an abbreviated year, as in '90s
+		return
+	case !s.isSpaceAt(i+1) && !s.isClosingContextAt(i+1):
+		isOpening, isQuote = true, true
+		return
+	}
+
+	isQuote = true
+	isOpening = s.openSingle == 0
+
+	return
+}
+
+// The returned flag tells the caller to drop the space after an opening
+// quote, which the narrow space of the French quotes replaces.
+func (s *parseTypographyState) writeQuote(i int, isOpening, isDouble bool) (skipNext bool) {
+	g := s.owners[i]
+
+	var quote string
+
+	switch {
+	case isDouble && isOpening:
+		quote = s.quotes.doubleOpen
+		s.openDouble++
+	case isDouble:
+		quote = s.quotes.doubleClose
+		if s.openDouble > 0 {
+			s.openDouble--
+		}
+	case isOpening:
+		quote = s.quotes.singleOpen
+		s.openSingle++
+	default:
+		quote = s.quotes.singleClose
+		if s.openSingle > 0 {
+			s.openSingle--
+		}
+	}
+
+	if isOpening {
+		s.openQuoteAt = i
+	} else if strings.HasPrefix(quote, parseTypographyFrenchSpace) && s.rune(i-1) == ' ' && s.isTransformable(i-1) {
+		// a space typed inside the quotes is replaced by the narrow one
+		if p := s.owners[i-1]; len(p.output) > 0 {
+			p.output = p.output[:len(p.output)-1]
+		}
+	}
+
+	g.output = append(g.output, []rune(quote)...)
+
+	skipNext = isOpening && strings.HasSuffix(quote, parseTypographyFrenchSpace) && s.rune(i+1) == ' ' && s.isTransformable(i+1)
+
+	return
+}
+
+// The characters of a replacement may be spread across several text nodes,
+// so the replacement is written to the first of them.
+func (s *parseTypographyState) replace(i int) (n int) {
+	for _, r := range parseTypographyReplacements {
+		if s.hasPrefixAt(i, r.from) {
+			s.owners[i].output = append(s.owners[i].output, []rune(r.to)...)
+			n = len(r.from)
+			return
+		}
+	}
+
+	for from, to := range parseTypographyFractions {
+		if !s.hasPrefixAt(i, from) {
+			continue
+		}
+
+		if b, a := s.rune(i-1), s.rune(i+len(from)); unicode.IsDigit(b) || unicode.IsDigit(a) || b == '/' || a == '/' || b == '.' {
+			continue
+		}
+
+		s.owners[i].output = append(s.owners[i].output, []rune(to)...)
+		n = len(from)
+		return
+	}
+
+	return
+}
+
+func (s *parseTypographyState) hasPrefixAt(i int, prefix string) bool {
+	for j, r := range []rune(prefix) {
+		if !s.isTransformable(i+j) || unicode.ToLower(s.runes[i+j]) != r {
+			return false
+		}
+	}
+
+	return true
+}
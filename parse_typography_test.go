@@ -0,0 +1,41 @@
+package slimdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTypography_locales(t *testing.T) {
+	const input = `She said "it's 'fine'" and left.`
+
+	for locale, expected := range map[TypographyLocale]string{
+		TypographyLocaleEnglish: "She said “it’s ‘fine’” and left.",
+		TypographyLocaleGerman:  "She said „it’s ‚fine‘“ and left.",
+		TypographyLocaleFrench:  "She said «\u202fit’s ‹\u202ffine\u202f›\u202f» and left.",
+	} {
+		options := DefaultOptions
+		options.EnableTypography = true
+		options.TypographyLocale = locale
+
+		doc, err := ParseString(input, &options)
+		if err != nil {
+			panic(err)
+		}
+
+		assert.Equal(t, expected, doc.Root.TextContent())
+	}
+}
+
+func TestParseTypography_frenchSpacing(t *testing.T) {
+	options := DefaultOptions
+	options.EnableTypography = true
+	options.TypographyLocale = TypographyLocaleFrench
+
+	doc, err := ParseString(`Il a dit " bonjour ".`, &options)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, "Il a dit «\u202fbonjour\u202f».", doc.Root.TextContent())
+}
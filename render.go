@@ -0,0 +1,301 @@
+package slimdown
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+type RenderWalkStatus uint8
+
+const (
+	RenderWalkContinue RenderWalkStatus = iota
+	RenderWalkSkip                      // skips the children and the exiting call
+)
+
+// Each method is called once when entering a node and once when exiting it.
+// Implementations must embed BaseRenderer (or HTMLRenderer or TextRenderer,
+// which embed it), so that they keep compiling when methods are added for new node kinds.
+type Renderer interface {
+	baseRenderer()
+	RenderDocument(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderParagraph(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderHeading(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderBlockquote(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderUnorderedList(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderOrderedList(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderListItem(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderCodeBlock(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderHorizontalRule(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderTable(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderTableHead(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderTableBody(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderTableRow(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderTableHeaderCell(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderTableCell(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderText(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderHTML(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderLineBreak(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderEmphasis(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderStrong(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderMark(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderDelete(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderInsert(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderSubscript(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderSuperscript(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderCode(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderLink(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderImage(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderTaskCheckbox(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderFootnoteReference(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderFootnotes(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+	RenderFootnote(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error)
+}
+
+// BaseRenderer writes nothing for any node, and walks into its children.
+type BaseRenderer struct{}
+
+func (BaseRenderer) baseRenderer() {}
+
+func (BaseRenderer) RenderDocument(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderParagraph(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderHeading(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderBlockquote(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderUnorderedList(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderOrderedList(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderListItem(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderCodeBlock(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderHorizontalRule(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderTable(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderTableHead(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderTableBody(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderTableRow(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderTableHeaderCell(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderTableCell(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderText(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderHTML(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderLineBreak(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderEmphasis(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderStrong(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderMark(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderDelete(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderInsert(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderSubscript(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderSuperscript(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderCode(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderLink(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderImage(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderTaskCheckbox(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderFootnoteReference(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderFootnotes(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (BaseRenderer) RenderFootnote(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func Render(doc *Document, options *Options) (output template.HTML, err error) {
+	if options == nil || options == &DefaultOptions {
+		options = DefaultOptions.clone()
+	}
+
+	renderer := options.Renderer
+	if renderer == nil {
+		renderer = HTMLRendererNew(options)
+	}
+
+	var builder strings.Builder
+
+	if err = RenderTo(&builder, doc, renderer); err != nil {
+		return
+	}
+
+	output = template.HTML(builder.String())
+
+	return
+}
+
+func RenderTo(w io.Writer, doc *Document, renderer Renderer) (err error) {
+	return renderNode(w, renderer, doc.Root)
+}
+
+func renderNode(w io.Writer, renderer Renderer, n *Node) (err error) {
+	status, err := renderNodeCall(w, renderer, n, true)
+	if err != nil || status == RenderWalkSkip {
+		return
+	}
+
+	for _, c := range n.Children {
+		if err = renderNode(w, renderer, c); err != nil {
+			return
+		}
+	}
+
+	_, err = renderNodeCall(w, renderer, n, false)
+
+	return
+}
+
+func renderNodeCall(w io.Writer, renderer Renderer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	switch n.Kind {
+	case NodeKindDocument:
+		return renderer.RenderDocument(w, n, entering)
+	case NodeKindParagraph:
+		return renderer.RenderParagraph(w, n, entering)
+	case NodeKindHeading:
+		return renderer.RenderHeading(w, n, entering)
+	case NodeKindBlockquote:
+		return renderer.RenderBlockquote(w, n, entering)
+	case NodeKindUnorderedList:
+		return renderer.RenderUnorderedList(w, n, entering)
+	case NodeKindOrderedList:
+		return renderer.RenderOrderedList(w, n, entering)
+	case NodeKindListItem:
+		return renderer.RenderListItem(w, n, entering)
+	case NodeKindCodeBlock:
+		return renderer.RenderCodeBlock(w, n, entering)
+	case NodeKindHorizontalRule:
+		return renderer.RenderHorizontalRule(w, n, entering)
+	case NodeKindTable:
+		return renderer.RenderTable(w, n, entering)
+	case NodeKindTableHead:
+		return renderer.RenderTableHead(w, n, entering)
+	case NodeKindTableBody:
+		return renderer.RenderTableBody(w, n, entering)
+	case NodeKindTableRow:
+		return renderer.RenderTableRow(w, n, entering)
+	case NodeKindTableHeaderCell:
+		return renderer.RenderTableHeaderCell(w, n, entering)
+	case NodeKindTableCell:
+		return renderer.RenderTableCell(w, n, entering)
+	case NodeKindText:
+		return renderer.RenderText(w, n, entering)
+	case NodeKindHTML:
+		return renderer.RenderHTML(w, n, entering)
+	case NodeKindLineBreak:
+		return renderer.RenderLineBreak(w, n, entering)
+	case NodeKindEmphasis:
+		return renderer.RenderEmphasis(w, n, entering)
+	case NodeKindStrong:
+		return renderer.RenderStrong(w, n, entering)
+	case NodeKindMark:
+		return renderer.RenderMark(w, n, entering)
+	case NodeKindDelete:
+		return renderer.RenderDelete(w, n, entering)
+	case NodeKindInsert:
+		return renderer.RenderInsert(w, n, entering)
+	case NodeKindSubscript:
+		return renderer.RenderSubscript(w, n, entering)
+	case NodeKindSuperscript:
+		return renderer.RenderSuperscript(w, n, entering)
+	case NodeKindCode:
+		return renderer.RenderCode(w, n, entering)
+	case NodeKindLink:
+		return renderer.RenderLink(w, n, entering)
+	case NodeKindImage:
+		return renderer.RenderImage(w, n, entering)
+	case NodeKindTaskCheckbox:
+		return renderer.RenderTaskCheckbox(w, n, entering)
+	case NodeKindFootnoteReference:
+		return renderer.RenderFootnoteReference(w, n, entering)
+	case NodeKindFootnotes:
+		return renderer.RenderFootnotes(w, n, entering)
+	case NodeKindFootnote:
+		return renderer.RenderFootnote(w, n, entering)
+	}
+
+	err = ErrRenderNodeKindUnknown
+
+	return
+}
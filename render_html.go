@@ -0,0 +1,308 @@
+package slimdown
+
+import (
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	htmlRendererAttributeEscaper = strings.NewReplacer(
+		`&`, "&amp;",
+		`"`, "&#34;",
+		`'`, "&#39;",
+		`<`, "&lt;",
+		`>`, "&gt;",
+	)
+)
+
+// The zero value renders with DefaultOptions, so that a renderer which
+// embeds HTMLRenderer to override a few methods needs no constructor.
+type HTMLRenderer struct {
+	BaseRenderer
+	Options *Options
+}
+
+func HTMLRendererNew(options *Options) *HTMLRenderer {
+	if options == nil || options == &DefaultOptions {
+		options = DefaultOptions.clone()
+	}
+
+	return &HTMLRenderer{
+		Options: options,
+	}
+}
+
+func (r *HTMLRenderer) options() *Options {
+	if r.Options == nil {
+		return &DefaultOptions
+	}
+
+	return r.Options
+}
+
+func (r *HTMLRenderer) RenderDocument(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if !r.options().EnableDocumentTags {
+		return
+	}
+
+	if entering {
+		if r.options().CleanEmptyTags {
+			_, err = io.WriteString(w, "<!DOCTYPE html><html><body>")
+		} else {
+			_, err = io.WriteString(w, "<!DOCTYPE html><html><head></head><body>")
+		}
+	} else {
+		_, err = io.WriteString(w, "</body></html>")
+	}
+
+	return
+}
+
+func (r *HTMLRenderer) RenderParagraph(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "p")
+}
+
+func (r *HTMLRenderer) RenderHeading(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	level := n.Level
+	if level < 1 {
+		level = 1
+	} else if level > 6 {
+		level = 6
+	}
+
+	return r.renderTags(w, n, entering, "h"+strconv.Itoa(level))
+}
+
+func (r *HTMLRenderer) RenderBlockquote(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "blockquote")
+}
+
+func (r *HTMLRenderer) RenderUnorderedList(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "ul")
+}
+
+func (r *HTMLRenderer) RenderOrderedList(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "ol")
+}
+
+func (r *HTMLRenderer) RenderListItem(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "li")
+}
+
+func (r *HTMLRenderer) RenderCodeBlock(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "pre", "code")
+}
+
+func (r *HTMLRenderer) RenderHorizontalRule(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderSelfClosingTag(w, n, entering, "hr")
+}
+
+func (r *HTMLRenderer) RenderTable(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "table")
+}
+
+func (r *HTMLRenderer) RenderTableHead(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "thead")
+}
+
+func (r *HTMLRenderer) RenderTableBody(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "tbody")
+}
+
+func (r *HTMLRenderer) RenderTableRow(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "tr")
+}
+
+func (r *HTMLRenderer) RenderTableHeaderCell(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "th")
+}
+
+func (r *HTMLRenderer) RenderTableCell(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "td")
+}
+
+func (r *HTMLRenderer) RenderText(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if entering {
+		_, err = io.WriteString(w, html.EscapeString(n.Text))
+	}
+
+	return
+}
+
+func (r *HTMLRenderer) RenderHTML(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if entering {
+		_, err = io.WriteString(w, n.Text)
+	}
+
+	return
+}
+
+func (r *HTMLRenderer) RenderLineBreak(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderSelfClosingTag(w, n, entering, "br")
+}
+
+func (r *HTMLRenderer) RenderEmphasis(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "em")
+}
+
+func (r *HTMLRenderer) RenderStrong(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "strong")
+}
+
+func (r *HTMLRenderer) RenderMark(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "mark")
+}
+
+func (r *HTMLRenderer) RenderDelete(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "del")
+}
+
+func (r *HTMLRenderer) RenderInsert(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "ins")
+}
+
+func (r *HTMLRenderer) RenderSubscript(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "sub")
+}
+
+func (r *HTMLRenderer) RenderSuperscript(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "sup")
+}
+
+func (r *HTMLRenderer) RenderCode(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "code")
+}
+
+func (r *HTMLRenderer) RenderLink(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "a")
+}
+
+func (r *HTMLRenderer) RenderImage(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderSelfClosingTag(w, n, entering, "img")
+}
+
+func (r *HTMLRenderer) RenderTaskCheckbox(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderSelfClosingTag(w, n, entering, "input")
+}
+
+func (r *HTMLRenderer) RenderFootnoteReference(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "sup", "a")
+}
+
+func (r *HTMLRenderer) RenderFootnotes(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	var builder strings.Builder
+
+	if entering {
+		r.writeOpeningTag(&builder, "section", n.Attributes)
+		builder.WriteString("<ol>")
+	} else {
+		builder.WriteString("</ol></section>")
+	}
+
+	_, err = io.WriteString(w, builder.String())
+
+	return
+}
+
+func (r *HTMLRenderer) RenderFootnote(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTags(w, n, entering, "li")
+}
+
+func (r *HTMLRenderer) renderSelfClosingTag(w io.Writer, n *Node, entering bool, tag string) (status RenderWalkStatus, err error) {
+	if !entering {
+		return
+	}
+
+	var builder strings.Builder
+
+	r.writeOpeningTag(&builder, tag, n.Attributes)
+
+	_, err = io.WriteString(w, builder.String())
+	status = RenderWalkSkip
+
+	return
+}
+
+func (r *HTMLRenderer) renderTags(w io.Writer, n *Node, entering bool, tags ...string) (status RenderWalkStatus, err error) {
+	var builder strings.Builder
+	tagsLen := len(tags)
+
+	if entering {
+		if r.options().CleanEmptyTags && htmlRendererNodeIsEmpty(n) {
+			status = RenderWalkSkip
+			return
+		}
+
+		for i, tag := range tags {
+			if i == tagsLen-1 {
+				r.writeOpeningTag(&builder, tag, n.Attributes)
+			} else {
+				r.writeOpeningTag(&builder, tag, nil)
+			}
+		}
+	} else {
+		for i := tagsLen - 1; i >= 0; i-- {
+			builder.WriteByte('<')
+			builder.WriteByte('/')
+			builder.WriteString(tags[i])
+			builder.WriteByte('>')
+		}
+	}
+
+	_, err = io.WriteString(w, builder.String())
+
+	return
+}
+
+func (r *HTMLRenderer) writeOpeningTag(builder *strings.Builder, tag string, attributes map[string]string) {
+	builder.WriteByte('<')
+	builder.WriteString(tag)
+
+	if len(attributes) > 0 {
+		keys := make([]string, 0, len(attributes))
+		for k := range attributes {
+			if k != "" {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			builder.WriteByte(' ')
+			builder.WriteString(k)
+
+			if v := attributes[k]; v != "" {
+				builder.WriteByte('=')
+				builder.WriteByte('"')
+				htmlRendererAttributeEscaper.WriteString(builder, v)
+				builder.WriteByte('"')
+			}
+		}
+	}
+
+	builder.WriteByte('>')
+}
+
+func htmlRendererNodeIsEmpty(n *Node) bool {
+	isEmpty := true
+
+	n.Walk(func(n2 *Node) bool {
+		switch n2.Kind {
+		case NodeKindText, NodeKindHTML:
+			if strings.TrimFunc(n2.Text, unicode.IsSpace) != "" {
+				isEmpty = false
+			}
+		case NodeKindImage:
+			isEmpty = false
+		}
+
+		return isEmpty
+	})
+
+	return isEmpty
+}
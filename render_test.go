@@ -0,0 +1,153 @@
+package slimdown
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRenderLinkRenderer struct {
+	*HTMLRenderer
+}
+
+func (r *testRenderLinkRenderer) RenderLink(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if entering {
+		href, _ := n.Attribute("href")
+		_, err = io.WriteString(w, `<a rel="nofollow" href="`+href+`">`)
+		return
+	}
+
+	_, err = io.WriteString(w, "</a>")
+
+	return
+}
+
+func TestRender_overrideLink(t *testing.T) {
+	options := DefaultOptions
+	options.Renderer = &testRenderLinkRenderer{HTMLRendererNew(&options)}
+
+	output, err := CompileString("See <https://example.com> and *more*.", &options)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(
+		t,
+		`<p>See <a rel="nofollow" href="https://example.com">https://example.com</a> and <em>more</em>.</p>`,
+		string(output),
+	)
+}
+
+type testRenderEmbeddedRenderer struct {
+	HTMLRenderer
+}
+
+func (r *testRenderEmbeddedRenderer) RenderEmphasis(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if entering {
+		_, err = io.WriteString(w, "<i>")
+		return
+	}
+
+	_, err = io.WriteString(w, "</i>")
+
+	return
+}
+
+func TestRender_zeroValueHTMLRenderer(t *testing.T) {
+	doc, err := ParseString("Some *text* and **more**.", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	output, err := Render(doc, &Options{Renderer: &testRenderEmbeddedRenderer{}})
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, "<p>Some <i>text</i> and <strong>more</strong>.</p>", string(output))
+}
+
+func TestRender_text(t *testing.T) {
+	doc, err := ParseString("# Title\n\nSome *text*.\n\n* one\n* two", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	var builder strings.Builder
+
+	if err = RenderTo(&builder, doc, TextRendererNew()); err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, "Title\nSome text.\none\ntwo\n", builder.String())
+}
+
+func TestRender_escapesAttributes(t *testing.T) {
+	doc, err := ParseString("See <https://example.com>.", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, n := range doc.Root.FindAll(NodeKindLink) {
+		n.SetAttribute("href", `https://example.com/?a=1&b="2"`)
+		n.SetAttribute("title", `"><script>alert('x')</script>`)
+	}
+
+	output, err := Render(doc, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(
+		t,
+		`<p>See <a href="https://example.com/?a=1&amp;b=&#34;2&#34;" title="&#34;&gt;&lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt;">https://example.com</a>.</p>`,
+		string(output),
+	)
+}
+
+func TestRender_textFootnotes(t *testing.T) {
+	options := DefaultOptions
+	options.EnableFootnotes = true
+
+	doc, err := ParseString("Text[^1].\n\n[^1]: The note.", &options)
+	if err != nil {
+		panic(err)
+	}
+
+	var builder strings.Builder
+
+	if err = RenderTo(&builder, doc, TextRendererNew()); err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, "Text[1].\n\n[1] The note. \n", builder.String())
+}
+
+type testRenderBaseRenderer struct {
+	BaseRenderer
+}
+
+func (r *testRenderBaseRenderer) RenderText(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if entering {
+		_, err = io.WriteString(w, strings.ToUpper(n.Text))
+	}
+
+	return
+}
+
+func TestRender_baseRenderer(t *testing.T) {
+	doc, err := ParseString("# Title\n\nSome *text* and a [link](/a).", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	var builder strings.Builder
+
+	if err = RenderTo(&builder, doc, &testRenderBaseRenderer{}); err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, "TITLESOME TEXT AND A LINK.", builder.String())
+}
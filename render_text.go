@@ -0,0 +1,199 @@
+package slimdown
+
+import (
+	"io"
+	"strconv"
+)
+
+type TextRenderer struct {
+	BaseRenderer
+}
+
+func TextRendererNew() *TextRenderer {
+	return &TextRenderer{}
+}
+
+func (r *TextRenderer) RenderDocument(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderParagraph(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderBlock(w, n, entering)
+}
+
+func (r *TextRenderer) RenderHeading(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderBlock(w, n, entering)
+}
+
+func (r *TextRenderer) RenderBlockquote(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderUnorderedList(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderOrderedList(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderListItem(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderBlock(w, n, entering)
+}
+
+func (r *TextRenderer) RenderCodeBlock(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderBlock(w, n, entering)
+}
+
+func (r *TextRenderer) RenderHorizontalRule(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderBlock(w, n, entering)
+}
+
+func (r *TextRenderer) RenderTable(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderTableHead(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderTableBody(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderTableRow(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderBlock(w, n, entering)
+}
+
+func (r *TextRenderer) RenderTableHeaderCell(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTableCell(w, n, entering)
+}
+
+func (r *TextRenderer) RenderTableCell(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return r.renderTableCell(w, n, entering)
+}
+
+func (r *TextRenderer) RenderText(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if entering {
+		_, err = io.WriteString(w, n.Text)
+	}
+
+	return
+}
+
+func (r *TextRenderer) RenderHTML(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderLineBreak(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if entering {
+		_, err = io.WriteString(w, "\n")
+	}
+
+	return
+}
+
+func (r *TextRenderer) RenderEmphasis(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderStrong(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderMark(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderDelete(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderInsert(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderSubscript(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderSuperscript(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderCode(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	return
+}
+
+func (r *TextRenderer) RenderLink(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if class, _ := n.Attribute("class"); class == parseFootnoteBackReferenceClass || class == parseHeadingAnchorClass {
+		status = RenderWalkSkip
+	}
+
+	return
+}
+
+func (r *TextRenderer) RenderImage(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if alt, ok := n.Attribute("alt"); entering && ok {
+		_, err = io.WriteString(w, alt)
+	}
+
+	return
+}
+
+func (r *TextRenderer) RenderTaskCheckbox(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if !entering {
+		return
+	}
+
+	if _, ok := n.Attribute("checked"); ok {
+		_, err = io.WriteString(w, "[x]")
+	} else {
+		_, err = io.WriteString(w, "[ ]")
+	}
+
+	return
+}
+
+func (r *TextRenderer) RenderFootnoteReference(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if entering {
+		_, err = io.WriteString(w, "[")
+	} else {
+		_, err = io.WriteString(w, "]")
+	}
+
+	return
+}
+
+func (r *TextRenderer) RenderFootnotes(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if entering {
+		_, err = io.WriteString(w, "\n")
+	}
+
+	return
+}
+
+func (r *TextRenderer) RenderFootnote(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if entering {
+		_, err = io.WriteString(w, "["+strconv.Itoa(n.Level)+"] ")
+		return
+	}
+
+	return r.renderBlock(w, n, entering)
+}
+
+func (r *TextRenderer) renderBlock(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if !entering {
+		_, err = io.WriteString(w, "\n")
+	}
+
+	return
+}
+
+func (r *TextRenderer) renderTableCell(w io.Writer, n *Node, entering bool) (status RenderWalkStatus, err error) {
+	if !entering && n.NextSibling() != nil {
+		_, err = io.WriteString(w, "\t")
+	}
+
+	return
+}